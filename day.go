@@ -1,6 +1,20 @@
 package reporter
 
-import "time"
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // Question describes a single possible question
 type Question struct {
@@ -10,14 +24,228 @@ type Question struct {
 	Placeholder  string `json:"placeholderString,omitempty"`
 }
 
+// The known values of Question.QuestionType, which indicate the format of the answer and the Response field it is stored in.
+const (
+	QuestionTypeTokens   = 0 // Free form tokens, stored in Tokens, i.e. What are you doing?
+	QuestionTypeYesNo    = 2 // Yes or No, stored in AnsweredOptions, i.e. Are you working?
+	QuestionTypeLocation = 3 // A place, stored in Location, i.e. Where are you?
+	QuestionTypePeople   = 4 // People, stored in Tokens, i.e. Who are you with?
+	QuestionTypeNote     = 6 // A free form note, stored in TextResponses, i.e. What did you learn today?
+)
+
+// QuestionsOfType returns the questions (schema version 2 only) with the given QuestionType, in the order the day lists them.
+// See the QuestionType constants for the known types.
+func (d *Day) QuestionsOfType(t int) []Question {
+	var questions []Question
+	for _, question := range d.Questions {
+		if question.QuestionType != nil && *question.QuestionType == t {
+			questions = append(questions, question)
+		}
+	}
+	return questions
+}
+
 // Day contains all snapshots, possible questions (schema version 2 only) and metadata about a specific day
 // Reporter writes one JSON file per day
 type Day struct {
 	Snapshots     []Snapshot `json:"snapshots,omitempty"`
 	Questions     []Question `json:"questions,omitempty"`
-	Date          time.Time  `json:"-,omitempty"` // Only filled when data wasn't loaded from string
-	FileInfo      File       `json:"-,omitempty"` // Only filled when data wasn't loaded from string
+	Date          time.Time  `json:"-"` // Only filled when data wasn't loaded from string
+	FileInfo      File       `json:"-"` // Only filled when data wasn't loaded from string
 	SchemaVersion int        `json:"-"`
+	Raw           []byte     `json:"-"` // The JSON the day was decoded from, only filled when DecodeOptions.KeepRaw is set
+
+	extra    map[string]json.RawMessage
+	timeZone string
+}
+
+type day Day
+
+var dayFields = jsonFieldNames(reflect.TypeOf(Day{}))
+
+// Extra returns any top level JSON fields found while decoding that are not otherwise mapped to the Day struct.
+// They are written back out when the day is marshaled so that fields added by newer versions of the app are not lost.
+func (d *Day) Extra() map[string]json.RawMessage {
+	return d.extra
+}
+
+// Clone returns a deep copy of the day and all of its snapshots so it can be modified without affecting the original
+func (d Day) Clone() Day {
+	var clone Day
+	deepCopy(reflect.ValueOf(&clone).Elem(), reflect.ValueOf(d))
+	clone.extra = copyJSONFields(d.extra)
+	for i := range d.Snapshots {
+		clone.Snapshots[i].extra = copyJSONFields(d.Snapshots[i].extra)
+	}
+	return clone
+}
+
+// MarshalJSON writes out the day along with any unknown fields captured while decoding
+func (d Day) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(day(d))
+	if err != nil {
+		return nil, err
+	}
+	return appendJSONFields(b, d.extra)
+}
+
+// binaryDay is the gob representation of a Day.
+// gob does not transmit zero values, so the paths to non-nil pointers to zero values are recorded to be restored when decoding.
+type binaryDay struct {
+	Day               day
+	Extra             map[string]json.RawMessage
+	SnapshotExtras    []map[string]json.RawMessage
+	SnapshotPhotoSets []binaryPhotoSets
+	ZeroPointers      [][]int
+}
+
+// binaryPhotoSets is the gob representation of the photo sets a snapshot's PhotoSet was merged from, if its JSON was an array
+type binaryPhotoSets struct {
+	IsArray bool
+	IDs     []string
+	Counts  []int
+}
+
+// MarshalBinary encodes the day using encoding/gob so it can be cached and reloaded without parsing the JSON again
+func (d Day) MarshalBinary() ([]byte, error) {
+	encoded := binaryDay{Day: day(d), Extra: d.extra}
+	for _, snapshot := range d.Snapshots {
+		encoded.SnapshotExtras = append(encoded.SnapshotExtras, snapshot.extra)
+		var sets binaryPhotoSets
+		if snapshot.PhotoSet != nil && snapshot.PhotoSet.sets != nil {
+			sets.IsArray = true
+			for _, shape := range snapshot.PhotoSet.sets {
+				sets.IDs = append(sets.IDs, shape.id)
+				sets.Counts = append(sets.Counts, shape.count)
+			}
+		}
+		encoded.SnapshotPhotoSets = append(encoded.SnapshotPhotoSets, sets)
+	}
+	zeroPointerPaths(reflect.ValueOf(encoded.Day), nil, &encoded.ZeroPointers)
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(encoded); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a day previously encoded with MarshalBinary
+func (d *Day) UnmarshalBinary(data []byte) error {
+	var decoded binaryDay
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&decoded); err != nil {
+		return err
+	}
+	for _, path := range decoded.ZeroPointers {
+		restoreZeroPointer(reflect.ValueOf(&decoded.Day).Elem(), path)
+	}
+	*d = Day(decoded.Day)
+	d.extra = decoded.Extra
+	for i, extra := range decoded.SnapshotExtras {
+		if i < len(d.Snapshots) {
+			d.Snapshots[i].extra = extra
+		}
+	}
+	for i, sets := range decoded.SnapshotPhotoSets {
+		if !sets.IsArray || i >= len(d.Snapshots) || d.Snapshots[i].PhotoSet == nil || len(sets.IDs) != len(sets.Counts) {
+			continue
+		}
+		shapes := []photoSetShape{}
+		for j, id := range sets.IDs {
+			shapes = append(shapes, photoSetShape{id, sets.Counts[j]})
+		}
+		d.Snapshots[i].PhotoSet.sets = shapes
+	}
+	return nil
+}
+
+// MarshalOptions control how MarshalJSONOptions writes out a day
+type MarshalOptions struct {
+	OmitDebugFields bool // Leaves out the unused Background, DwellStatus and Sync snapshot fields
+}
+
+// MarshalJSONOptions marshals the day using its schema version and the given options.
+// Calling it with the zero value of MarshalOptions is equivalent to marshaling the day as is.
+func (d Day) MarshalJSONOptions(opts MarshalOptions) ([]byte, error) {
+	if opts.OmitDebugFields {
+		snapshots := make([]Snapshot, len(d.Snapshots))
+		for i, snapshot := range d.Snapshots {
+			snapshot.Background = nil
+			snapshot.DwellStatus = nil
+			snapshot.Sync = nil
+			snapshots[i] = snapshot
+		}
+		d.Snapshots = snapshots
+	}
+	return marshalForSchemaVersion(d, d.SchemaVersion)
+}
+
+// JSON returns the day marshaled using its schema version, so timestamps and tokens are written the way the app wrote them.
+// If the day has Raw JSON it is returned verbatim instead, so clear Raw after modifying the day to have the changes written.
+func (d Day) JSON() ([]byte, error) {
+	if d.Raw != nil {
+		return append([]byte(nil), d.Raw...), nil
+	}
+	return marshalForSchemaVersion(d, d.SchemaVersion)
+}
+
+// JSONIndent is like JSON but indents the output with two spaces for readability
+func (d Day) JSONIndent() ([]byte, error) {
+	b, err := d.JSON()
+	if err != nil {
+		return nil, err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, b, "", "  "); err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
+
+// UnmarshalJSON decodes the day, capturing any fields not mapped to the Day struct.
+// The schema version the JSON was written with, if it can be detected, is stored in SchemaVersion.
+func (d *Day) UnmarshalJSON(b []byte) error {
+	if err := d.unmarshalJSON(b, fieldSkips{}); err != nil {
+		return err
+	}
+	if version := schemaVersionOf(b, reflect.TypeOf(Day{})); version != 0 {
+		decodeMutex.Lock()
+		SchemaVersion = version
+		decodeMutex.Unlock()
+	}
+	return nil
+}
+
+// unmarshalJSON is UnmarshalJSON, leaving the snapshot fields selected by skips nil
+func (d *Day) unmarshalJSON(b []byte, skips fieldSkips) error {
+	var decoded day
+	if skips == (fieldSkips{}) {
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			return err
+		}
+	} else {
+		// The snapshots are shadowed so each can be decoded with the skips
+		target := struct {
+			*day
+			Snapshots []json.RawMessage `json:"snapshots"`
+		}{day: &decoded}
+		if err := json.Unmarshal(b, &target); err != nil {
+			return err
+		}
+		for _, rawSnapshot := range target.Snapshots {
+			var snapshot Snapshot
+			if err := snapshot.unmarshalJSON(rawSnapshot, skips); err != nil {
+				return err
+			}
+			decoded.Snapshots = append(decoded.Snapshots, snapshot)
+		}
+	}
+	extra, err := unknownJSONFields(b, dayFields)
+	if err != nil {
+		return err
+	}
+	*d = Day(decoded)
+	d.extra = extra
+	return nil
 }
 
 // GetEarliestSnapshot returns the first snapshot for a given day
@@ -29,3 +257,1073 @@ func (d *Day) GetEarliestSnapshot() Snapshot {
 func (d *Day) GetLatestSnapshot() Snapshot {
 	return d.Snapshots[len(d.Snapshots)-1]
 }
+
+// WeatherSummary returns the minimum and maximum TemperatureCelsius across all snapshots along with the most common WeatherDescription.
+// ok will be false if no snapshot recorded a temperature, in which case min and max are 0 but dominant is still set from any descriptions.
+// Ties for the dominant condition resolve to the earliest seen description.
+func (d *Day) WeatherSummary() (min, max float64, dominant string, ok bool) {
+	counts := make(map[string]int)
+	var order []string
+	for _, snapshot := range d.Snapshots {
+		if snapshot.Weather == nil {
+			continue
+		}
+		if temp := snapshot.Weather.TemperatureCelsius; temp != nil {
+			if !ok || *temp < min {
+				min = *temp
+			}
+			if !ok || *temp > max {
+				max = *temp
+			}
+			ok = true
+		}
+		if description := snapshot.Weather.WeatherDescription; description != "" {
+			if counts[description] == 0 {
+				order = append(order, description)
+			}
+			counts[description]++
+		}
+	}
+	for _, description := range order {
+		if counts[description] > counts[dominant] {
+			dominant = description
+		}
+	}
+	return
+}
+
+// ToFile returns a File containing the JSON for the day, ready to be written back to a backend.
+// The JSON is marshaled using the schema version of the day and the filename is derived from the EffectiveTime of the first timestamped snapshot.
+// If no snapshot has a date, Day.Date is used instead.
+func (d *Day) ToFile() (File, error) {
+	var reporterFile File
+	date := d.Date
+	for _, snapshot := range d.Snapshots {
+		if snapshotTime, ok := snapshot.EffectiveTime(); ok {
+			date = snapshotTime
+			break
+		}
+	}
+	if date.IsZero() {
+		return reporterFile, errors.New("Unable to determine the date of the day to build a filename")
+	}
+	contents, err := marshalForSchemaVersion(d, d.SchemaVersion)
+	if err != nil {
+		return reporterFile, err
+	}
+	return File{
+		Name:             filenameForDate(date),
+		Source:           d.FileInfo.Source,
+		TimeFromFilename: truncateToDay(date),
+		Contents:         string(contents),
+	}, nil
+}
+
+// Section is a group of snapshots sharing the same SectionIdentifier, as displayed by the app
+type Section struct {
+	Identifier string
+	Snapshots  []Snapshot
+}
+
+// SnapshotsBySection groups the snapshots of the day by their SectionIdentifier.
+// Sections are returned in the order they first appear in the day.
+func (d *Day) SnapshotsBySection() []Section {
+	var sections []Section
+	indexes := make(map[string]int)
+	for _, snapshot := range d.Snapshots {
+		index, ok := indexes[snapshot.SectionIdentifier]
+		if !ok {
+			index = len(sections)
+			indexes[snapshot.SectionIdentifier] = index
+			sections = append(sections, Section{Identifier: snapshot.SectionIdentifier})
+		}
+		sections[index].Snapshots = append(sections[index].Snapshots, snapshot)
+	}
+	return sections
+}
+
+// AllSnapshotsSeq returns an iterator over the snapshots of the day that does not copy the snapshot slice
+func (d *Day) AllSnapshotsSeq() iter.Seq[Snapshot] {
+	return func(yield func(Snapshot) bool) {
+		for _, snapshot := range d.Snapshots {
+			if !yield(snapshot) {
+				return
+			}
+		}
+	}
+}
+
+// AllPhotos returns every photo taken during the day across all snapshots, in snapshot order
+func (d *Day) AllPhotos() []Photo {
+	var photos []Photo
+	for _, snapshot := range d.Snapshots {
+		if snapshot.PhotoSet != nil {
+			photos = append(photos, snapshot.PhotoSet.Photos...)
+		}
+	}
+	return photos
+}
+
+// AllPhotosByTakenAt returns every photo taken during the day sorted by when the shutter fired (see Photo.TakenAt).
+// Photos without an EXIF DateTime are placed using the EffectiveTime of their snapshot instead,
+// and photos without either are placed at the end in snapshot order.
+func (d *Day) AllPhotosByTakenAt() []Photo {
+	type timedPhoto struct {
+		photo Photo
+		time  time.Time
+		ok    bool
+	}
+	var timed []timedPhoto
+	for _, snapshot := range d.Snapshots {
+		if snapshot.PhotoSet == nil {
+			continue
+		}
+		snapshotTime, snapshotOk := snapshot.EffectiveTime()
+		for _, photo := range snapshot.PhotoSet.Photos {
+			if takenAt, ok := photo.TakenAt(); ok {
+				timed = append(timed, timedPhoto{photo, takenAt, true})
+			} else {
+				timed = append(timed, timedPhoto{photo, snapshotTime, snapshotOk})
+			}
+		}
+	}
+	sort.SliceStable(timed, func(i, j int) bool {
+		if timed[i].ok != timed[j].ok {
+			return timed[i].ok
+		}
+		return timed[i].time.Before(timed[j].time)
+	})
+	photos := make([]Photo, 0, len(timed))
+	for _, photo := range timed {
+		photos = append(photos, photo.photo)
+	}
+	return photos
+}
+
+// PhotoCount returns the number of photos taken during the day
+func (d *Day) PhotoCount() int {
+	count := 0
+	for _, snapshot := range d.Snapshots {
+		if snapshot.PhotoSet != nil {
+			count += len(snapshot.PhotoSet.Photos)
+		}
+	}
+	return count
+}
+
+// AnsweredOptionsByQuestion returns every option answered during the day, grouped by QuestionPrompt.
+// Options are de-duplicated within each question and kept in the order they were first answered.
+func (d *Day) AnsweredOptionsByQuestion() map[string][]string {
+	options := make(map[string][]string)
+	seen := make(map[string]map[string]bool)
+	for _, snapshot := range d.Snapshots {
+		for _, response := range snapshot.Responses {
+			if response == nil || len(response.AnsweredOptions) == 0 {
+				continue
+			}
+			if seen[response.QuestionPrompt] == nil {
+				seen[response.QuestionPrompt] = make(map[string]bool)
+			}
+			for _, option := range response.AnsweredOptions {
+				if !seen[response.QuestionPrompt][option] {
+					seen[response.QuestionPrompt][option] = true
+					options[response.QuestionPrompt] = append(options[response.QuestionPrompt], option)
+				}
+			}
+		}
+	}
+	return options
+}
+
+// NumericAnswers returns the parsed NumericResponse of every response whose QuestionPrompt contains promptContains, ignoring case,
+// in the order the snapshots were taken. Responses without a number, or with one that can't be parsed, are skipped.
+func (d *Day) NumericAnswers(promptContains string) []float64 {
+	var answers []float64
+	needle := strings.ToLower(promptContains)
+	for _, snapshot := range d.Snapshots {
+		for _, response := range snapshot.Responses {
+			if response == nil || response.NumericResponse == "" || !strings.Contains(strings.ToLower(response.QuestionPrompt), needle) {
+				continue
+			}
+			if value, err := strconv.ParseFloat(strings.TrimSpace(response.NumericResponse), 64); err == nil {
+				answers = append(answers, value)
+			}
+		}
+	}
+	return answers
+}
+
+// RedactOptions selects which location data Redact removes from a day
+type RedactOptions struct {
+	Location   bool // Removes the Location of every snapshot and location response
+	Weather    bool // Removes the latitude, longitude and station ID from weather
+	Photos     bool // Removes the GPS coordinates and altitude from photo EXIF data
+	Placemarks bool // Removes street level details from placemarks, keeping the locality, administrative areas and country
+}
+
+// Redact removes location data from the day in place according to opts.
+// Non-location data such as steps, battery and responses is left intact.
+func (d *Day) Redact(opts RedactOptions) {
+	for i := range d.Snapshots {
+		snapshot := &d.Snapshots[i]
+		if opts.Placemarks {
+			redactPlacemark(snapshot.Location)
+		}
+		if opts.Location {
+			snapshot.Location = nil
+		}
+		if opts.Weather && snapshot.Weather != nil {
+			snapshot.Weather.Latitude = nil
+			snapshot.Weather.Longitude = nil
+			snapshot.Weather.StationID = ""
+		}
+		if opts.Photos && snapshot.PhotoSet != nil {
+			for j := range snapshot.PhotoSet.Photos {
+				photo := &snapshot.PhotoSet.Photos[j]
+				photo.Latitude = nil
+				photo.LatitudeRef = ""
+				photo.Longitude = nil
+				photo.LongitudeRef = ""
+				photo.Altitude = nil
+			}
+		}
+		for _, response := range snapshot.Responses {
+			if response == nil || response.Location == nil {
+				continue
+			}
+			if opts.Placemarks {
+				redactPlacemark(response.Location.Location)
+			}
+			if opts.Location {
+				response.Location.Location = nil
+			}
+		}
+	}
+}
+
+// redactPlacemark removes the street level details from the placemark of a location
+func redactPlacemark(location *Location) {
+	if location == nil || location.Placemark == nil {
+		return
+	}
+	placemark := location.Placemark
+	placemark.Name = ""
+	placemark.SubThoroughfare = ""
+	placemark.Thoroughfare = ""
+	placemark.SubLocality = ""
+	placemark.PostalCode = ""
+	placemark.Region = nil
+}
+
+// InferTimeZone returns the timezone identifier (i.e. America/Los_Angeles) the day was recorded in.
+// It resolves the timezone of the most frequently reported coordinate, rounded to roughly 100 meters.
+// The result is cached on the day so the resolver is only called once.
+func (d *Day) InferTimeZone(resolver TimezoneResolver) (string, error) {
+	if d.timeZone != "" {
+		return d.timeZone, nil
+	}
+	type coordinate struct{ lat, long float64 }
+	counts := make(map[coordinate]int)
+	timestamps := make(map[coordinate]time.Time)
+	var mostFrequent coordinate
+	for _, snapshot := range d.Snapshots {
+		location := snapshot.Location
+		if location == nil || location.Latitude == nil || location.Longitude == nil {
+			continue
+		}
+		snapshotTime, ok := snapshot.EffectiveTime()
+		if !ok {
+			continue
+		}
+		key := coordinate{roundPlus(*location.Latitude, 3), roundPlus(*location.Longitude, 3)}
+		if counts[key] == 0 {
+			timestamps[key] = snapshotTime
+		}
+		counts[key]++
+		if counts[key] > counts[mostFrequent] {
+			mostFrequent = key
+		}
+	}
+	if len(counts) == 0 {
+		return "", errors.New("No snapshots with a location and time to infer the timezone from")
+	}
+	timeZone, err := resolver.TimezoneForLocation(context.Background(), timestamps[mostFrequent].Unix(), mostFrequent.lat, mostFrequent.long)
+	if err != nil {
+		return "", fmt.Errorf("Unable to resolve timezone: %w", err)
+	}
+	d.timeZone = timeZone
+	return timeZone, nil
+}
+
+// HourStats summarizes the snapshots reported within a single hour of the day
+type HourStats struct {
+	SnapshotCount  int
+	AverageBattery float64 // The average BatteryFraction of snapshots in the hour that recorded it
+	TotalSteps     int     // Snapshots without a step count (recorded as -1 in schema version 1) are not counted
+	AverageAudio   float64 // The average Audio.Average in raw dB of snapshots in the hour that recorded it
+}
+
+// AggregateByHour buckets the snapshots of the day by the hour (0-23) of their EffectiveTime in loc.
+// Only hours that have snapshots are included. If loc is nil, time.Local is used.
+func (d *Day) AggregateByHour(loc *time.Location) map[int]HourStats {
+	if loc == nil {
+		loc = time.Local
+	}
+	stats := make(map[int]HourStats)
+	batteryCounts := make(map[int]int)
+	audioCounts := make(map[int]int)
+	for _, snapshot := range d.Snapshots {
+		snapshotTime, ok := snapshot.EffectiveTime()
+		if !ok {
+			continue
+		}
+		hour := snapshotTime.In(loc).Hour()
+		hourStats := stats[hour]
+		hourStats.SnapshotCount++
+		if battery, ok := snapshot.BatteryFraction(); ok {
+			hourStats.AverageBattery += battery
+			batteryCounts[hour]++
+		}
+		if snapshot.Steps != nil && *snapshot.Steps > 0 {
+			hourStats.TotalSteps += *snapshot.Steps
+		}
+		if snapshot.Audio != nil && snapshot.Audio.Average != nil {
+			hourStats.AverageAudio += *snapshot.Audio.Average
+			audioCounts[hour]++
+		}
+		stats[hour] = hourStats
+	}
+	for hour, hourStats := range stats {
+		if batteryCounts[hour] > 0 {
+			hourStats.AverageBattery /= float64(batteryCounts[hour])
+		}
+		if audioCounts[hour] > 0 {
+			hourStats.AverageAudio /= float64(audioCounts[hour])
+		}
+		stats[hour] = hourStats
+	}
+	return stats
+}
+
+// AudioStats returns the minimum, maximum and mean of the positive dB averages (see Audio.PositiveAverageDb) across the day.
+// ok will be false if no snapshot recorded audio.
+func (d *Day) AudioStats() (minDb, maxDb, avgDb float64, ok bool) {
+	var total float64
+	var count int
+	for _, snapshot := range d.Snapshots {
+		if snapshot.Audio == nil || snapshot.Audio.Average == nil {
+			continue
+		}
+		value := snapshot.Audio.PositiveAverageDb(false)
+		if count == 0 || value < minDb {
+			minDb = value
+		}
+		if count == 0 || value > maxDb {
+			maxDb = value
+		}
+		total += value
+		count++
+	}
+	if count == 0 {
+		return 0, 0, 0, false
+	}
+	return minDb, maxDb, total / float64(count), true
+}
+
+// CompletionRate returns the fraction of the day's questions that were answered at least once.
+// ok will be false if the day has no questions, as is the case for schema version 1.
+func (d *Day) CompletionRate() (rate float64, ok bool) {
+	if len(d.Questions) == 0 {
+		return 0, false
+	}
+	prompts := make(map[string]bool)
+	for _, question := range d.Questions {
+		prompts[question.Prompt] = false
+	}
+	answered := 0
+	for _, snapshot := range d.Snapshots {
+		for _, response := range snapshot.Responses {
+			if response == nil {
+				continue
+			}
+			if wasAnswered, isQuestion := prompts[response.QuestionPrompt]; isQuestion && !wasAnswered {
+				prompts[response.QuestionPrompt] = true
+				answered++
+			}
+		}
+	}
+	return float64(answered) / float64(len(prompts)), true
+}
+
+// SnapshotsWithAccuracyBetter returns the snapshots whose Location has a HorizontalAccuracy of meters or better
+func (d *Day) SnapshotsWithAccuracyBetter(meters float64) []Snapshot {
+	var snapshots []Snapshot
+	for _, snapshot := range d.Snapshots {
+		if snapshot.Location != nil && snapshot.Location.HorizontalAccuracy != nil && *snapshot.Location.HorizontalAccuracy <= meters {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots
+}
+
+// TotalDistanceMeters returns the distance travelled between consecutive located snapshots, in time order.
+// If maxAccuracyMeters is greater than 0, only snapshots with a HorizontalAccuracy of maxAccuracyMeters or better are used,
+// which keeps GPS jitter from poor fixes out of the total.
+func (d *Day) TotalDistanceMeters(maxAccuracyMeters float64) float64 {
+	snapshots := append([]Snapshot(nil), d.Snapshots...)
+	if maxAccuracyMeters > 0 {
+		snapshots = d.SnapshotsWithAccuracyBetter(maxAccuracyMeters)
+	}
+	sortSnapshots(snapshots)
+	var total, previousLat, previousLong float64
+	havePrevious := false
+	for _, snapshot := range snapshots {
+		lat, long, ok := snapshot.Coordinates()
+		if !ok {
+			continue
+		}
+		if havePrevious {
+			total += haversineMeters(previousLat, previousLong, lat, long)
+		}
+		previousLat, previousLong, havePrevious = lat, long, true
+	}
+	return total
+}
+
+// ReassignIDs replaces every uniqueIdentifier in the day, including those of questions, snapshots, responses, photos and locations,
+// with a freshly generated UUID. An identifier that appears more than once is given the same new UUID everywhere,
+// so references between objects are preserved. Empty identifiers are left empty.
+func (d *Day) ReassignIDs() {
+	reassigned := make(map[string]string)
+	generated := make(map[string]bool)
+	reassignIDs(reflect.ValueOf(d).Elem(), reassigned, generated)
+}
+
+// reassignIDs walks v, replacing the ID field of every struct mapped to uniqueIdentifier
+func reassignIDs(v reflect.Value, reassigned map[string]string, generated map[string]bool) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			reassignIDs(v.Elem(), reassigned, generated)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			reassignIDs(v.Index(i), reassigned, generated)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			if field.Name == "ID" && strings.HasPrefix(field.Tag.Get("json"), "uniqueIdentifier") {
+				id := v.Field(i).String()
+				if id == "" || generated[id] {
+					continue
+				}
+				if _, ok := reassigned[id]; !ok {
+					reassigned[id] = newUUID()
+					generated[reassigned[id]] = true
+				}
+				v.Field(i).SetString(reassigned[id])
+				continue
+			}
+			reassignIDs(v.Field(i), reassigned, generated)
+		}
+	}
+}
+
+// UsageProxy returns a rough score between 0 and 1 of how intensely the phone was used during the day.
+// It is a weighted average of three components, each normalized to between 0 and 1:
+//
+// Battery drain (weight 0.5): the total battery drop between consecutive reports per hour, where 15% an hour or more is 1
+//
+// Reports (weight 0.25): the number of reports, where 24 or more is 1
+//
+// Cellular (weight 0.25): the share of connected time spent on cellular rather than WiFi, attributing each interval to the connection of the earlier report
+//
+// Components that can't be calculated because fields are missing are left out and the remaining weights are scaled up.
+// A day without snapshots scores 0.
+func (d *Day) UsageProxy() float64 {
+	snapshots := append([]Snapshot(nil), d.Snapshots...)
+	sortSnapshots(snapshots)
+	var drain float64
+	var drainDuration, cellular, connected time.Duration
+	for i := 1; i < len(snapshots); i++ {
+		previous, current := snapshots[i-1], snapshots[i]
+		previousTime, previousOk := previous.EffectiveTime()
+		currentTime, currentOk := current.EffectiveTime()
+		if !previousOk || !currentOk {
+			continue
+		}
+		interval := currentTime.Sub(previousTime)
+		previousBattery, previousHasBattery := previous.BatteryFraction()
+		currentBattery, currentHasBattery := current.BatteryFraction()
+		if previousHasBattery && currentHasBattery {
+			if currentBattery < previousBattery {
+				drain += previousBattery - currentBattery
+			}
+			drainDuration += interval
+		}
+		if previous.IsOnline() {
+			connected += interval
+			if previous.Connection.Type == 0 {
+				cellular += interval
+			}
+		}
+	}
+	var score, weights float64
+	addComponent := func(weight, value float64) {
+		score += weight * math.Min(value, 1)
+		weights += weight
+	}
+	if drainDuration > 0 {
+		addComponent(0.5, drain/drainDuration.Hours()/0.15)
+	}
+	if len(snapshots) > 0 {
+		addComponent(0.25, float64(len(snapshots))/24)
+	}
+	if connected > 0 {
+		addComponent(0.25, float64(cellular)/float64(connected))
+	}
+	if weights == 0 {
+		return 0
+	}
+	return score / weights
+}
+
+// ManualSnapshots returns the snapshots that were filed by tapping the report button.
+// Snapshots without a ReportImpetus, such as those from schema version 1, are left out because how they were triggered is unknown.
+func (d *Day) ManualSnapshots() []Snapshot {
+	var snapshots []Snapshot
+	for _, snapshot := range d.Snapshots {
+		if snapshot.ReportImpetus != nil && !snapshot.ReportImpetus.IsAutomatic() {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots
+}
+
+// String returns a short human readable summary of the day, i.e.
+//
+//	2015-10-23: 4 snapshots (schema version 2) from 00:10 to 15:05
+func (d Day) String() string {
+	snapshots := append([]Snapshot(nil), d.Snapshots...)
+	sortSnapshots(snapshots)
+	date := "Unknown date"
+	if !d.Date.IsZero() {
+		date = d.Date.Format("2006-01-02")
+	} else if len(snapshots) > 0 {
+		if calendarDay, ok := snapshots[0].CalendarDay(); ok {
+			date = calendarDay.Format("2006-01-02")
+		}
+	}
+	noun := "snapshots"
+	if len(snapshots) == 1 {
+		noun = "snapshot"
+	}
+	summary := fmt.Sprintf("%s: %d %s (schema version %d)", date, len(snapshots), noun, d.SchemaVersion)
+	var first, last time.Time
+	for _, snapshot := range snapshots {
+		if snapshotTime, ok := snapshot.EffectiveTime(); ok {
+			if first.IsZero() {
+				first = snapshotTime
+			}
+			last = snapshotTime
+		}
+	}
+	if !first.IsZero() {
+		summary += fmt.Sprintf(" from %s to %s", first.Format("15:04"), last.Format("15:04"))
+	}
+	return summary
+}
+
+// ForEachSnapshot calls fn with the index of each snapshot and a pointer to it, so snapshots can be modified in place.
+// Iteration stops as soon as fn returns false.
+func (d *Day) ForEachSnapshot(fn func(i int, s *Snapshot) bool) {
+	for i := range d.Snapshots {
+		if !fn(i, &d.Snapshots[i]) {
+			return
+		}
+	}
+}
+
+// UpdateSnapshot calls fn with the snapshot whose uniqueIdentifier is id so it can be modified in place.
+// It returns false if no snapshot has that identifier.
+func (d *Day) UpdateSnapshot(id string, fn func(*Snapshot)) bool {
+	found := false
+	d.ForEachSnapshot(func(i int, snapshot *Snapshot) bool {
+		if snapshot.ID == id {
+			fn(snapshot)
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// metricTrend returns the first and last values of a snapshot metric in time order and the change between them.
+// ok will be false if fewer than two timestamped snapshots have the metric.
+func (d *Day) metricTrend(metric func(*Snapshot) (float64, bool)) (start, end, delta float64, ok bool) {
+	snapshots := append([]Snapshot(nil), d.Snapshots...)
+	sortSnapshots(snapshots)
+	count := 0
+	for i := range snapshots {
+		if _, hasTime := snapshots[i].EffectiveTime(); !hasTime {
+			continue
+		}
+		value, hasMetric := metric(&snapshots[i])
+		if !hasMetric {
+			continue
+		}
+		if count == 0 {
+			start = value
+		}
+		end = value
+		count++
+	}
+	if count < 2 {
+		return 0, 0, 0, false
+	}
+	return start, end, end - start, true
+}
+
+// TemperatureTrend compares the temperature in Celsius of the earliest and latest snapshots that recorded one.
+// ok will be false if fewer than two snapshots have a temperature.
+func (d *Day) TemperatureTrend() (startC, endC, delta float64, ok bool) {
+	return d.metricTrend((*Snapshot).TemperatureCelsius)
+}
+
+// PressureTrend compares the barometric pressure in millibars of the earliest and latest snapshots that recorded one.
+// Falling pressure often means incoming weather. ok will be false if fewer than two snapshots have a pressure.
+func (d *Day) PressureTrend() (startMb, endMb, delta float64, ok bool) {
+	return d.metricTrend((*Snapshot).PressureMillibars)
+}
+
+// CadenceWindow is the walking rate between two consecutive reports
+type CadenceWindow struct {
+	Start          time.Time
+	End            time.Time
+	StepsPerMinute float64
+}
+
+// CadenceWindows divides the steps of each snapshot by the minutes elapsed since the previous report, in time order.
+// Windows where either report lacks a time or steps (recorded as -1 in schema version 1), or where no time elapsed, are skipped.
+func (d *Day) CadenceWindows() []CadenceWindow {
+	snapshots := append([]Snapshot(nil), d.Snapshots...)
+	sortSnapshots(snapshots)
+	var windows []CadenceWindow
+	for i := 1; i < len(snapshots); i++ {
+		previous, current := snapshots[i-1], snapshots[i]
+		start, startOk := previous.EffectiveTime()
+		end, endOk := current.EffectiveTime()
+		if !startOk || !endOk || previous.Steps == nil || current.Steps == nil || *previous.Steps < 0 || *current.Steps < 0 {
+			continue
+		}
+		minutes := end.Sub(start).Minutes()
+		if minutes <= 0 {
+			continue
+		}
+		windows = append(windows, CadenceWindow{start, end, float64(*current.Steps) / minutes})
+	}
+	return windows
+}
+
+// chargingNoiseThreshold is the smallest change in BatteryFraction between reports that ChargingPeriods treats as charging or draining
+const chargingNoiseThreshold = 0.01
+
+// chargingMinimumRises is the number of rises in a row ChargingPeriods requires before a stretch counts as a period
+const chargingMinimumRises = 2
+
+// ChargingPeriod is a stretch of the day where the battery level rose between reports, suggesting the device was plugged in
+type ChargingPeriod struct {
+	Start time.Time
+	End   time.Time
+}
+
+// ChargingPeriods returns the stretches of the day, in time order, where the battery level rose across at least two consecutive reports in a row,
+// so at least three reports are needed for a period. Levels are compared as a BatteryFraction, so percentage exports behave the same.
+// Changes of 1% or less are treated as noise, so they neither start nor end a period, and a period ends when the battery drains.
+// Each period starts at the report before the first rise and ends at the report after the last one. Snapshots without a time or battery are skipped.
+func (d *Day) ChargingPeriods() []ChargingPeriod {
+	snapshots := append([]Snapshot(nil), d.Snapshots...)
+	sortSnapshots(snapshots)
+	var periods []ChargingPeriod
+	// The baseline is the last report whose battery level changed by more than the noise threshold
+	var baselineTime time.Time
+	var baselineBattery float64
+	hasBaseline := false
+	// The rising stretch being tracked, which only becomes a period once it has enough rises
+	var current ChargingPeriod
+	rises := 0
+	endStretch := func() {
+		if rises >= chargingMinimumRises {
+			periods = append(periods, current)
+		}
+		rises = 0
+	}
+	for _, snapshot := range snapshots {
+		snapshotTime, hasTime := snapshot.EffectiveTime()
+		battery, hasBattery := snapshot.BatteryFraction()
+		if !hasTime || !hasBattery {
+			continue
+		}
+		if hasBaseline {
+			change := battery - baselineBattery
+			if math.Abs(change) <= chargingNoiseThreshold {
+				continue
+			}
+			if change > 0 {
+				if rises == 0 {
+					current.Start = baselineTime
+				}
+				current.End = snapshotTime
+				rises++
+			} else {
+				endStretch()
+			}
+		}
+		baselineTime, baselineBattery, hasBaseline = snapshotTime, battery, true
+	}
+	endStretch()
+	return periods
+}
+
+// trackPoints returns the [longitude, latitude] of every snapshot with coordinates and a time, in time order, along with those times
+func (d *Day) trackPoints() ([][2]float64, []time.Time) {
+	snapshots := append([]Snapshot(nil), d.Snapshots...)
+	sortSnapshots(snapshots)
+	var points [][2]float64
+	var times []time.Time
+	for i := range snapshots {
+		lat, long, hasCoordinates := snapshots[i].Coordinates()
+		snapshotTime, hasTime := snapshots[i].EffectiveTime()
+		if !hasCoordinates || !hasTime {
+			continue
+		}
+		points = append(points, [2]float64{long, lat})
+		times = append(times, snapshotTime)
+	}
+	return points, times
+}
+
+// SimplifiedPath returns the [longitude, latitude] of the located snapshots in time order, simplified with the Ramer–Douglas–Peucker algorithm
+// so that no dropped point was more than toleranceMeters from the simplified path. The first and last points are always kept.
+// Snapshots without coordinates or a time are skipped. If toleranceMeters isn't greater than 0, every point is returned.
+func (d *Day) SimplifiedPath(toleranceMeters float64) [][2]float64 {
+	points, _ := d.trackPoints()
+	if toleranceMeters <= 0 || len(points) < 3 {
+		return points
+	}
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+	simplifyPath(points, 0, len(points)-1, toleranceMeters, keep)
+	var simplified [][2]float64
+	for i, point := range points {
+		if keep[i] {
+			simplified = append(simplified, point)
+		}
+	}
+	return simplified
+}
+
+// simplifyPath marks the points between first and last that must be kept for the path to stay within toleranceMeters
+func simplifyPath(points [][2]float64, first, last int, toleranceMeters float64, keep []bool) {
+	farthest, farthestMeters := -1, toleranceMeters
+	for i := first + 1; i < last; i++ {
+		if distance := segmentDistanceMeters(points[i], points[first], points[last]); distance > farthestMeters {
+			farthest, farthestMeters = i, distance
+		}
+	}
+	if farthest == -1 {
+		return
+	}
+	keep[farthest] = true
+	simplifyPath(points, first, farthest, toleranceMeters, keep)
+	simplifyPath(points, farthest, last, toleranceMeters, keep)
+}
+
+// segmentDistanceMeters returns the distance in meters from the [longitude, latitude] point p to the segment between a and b.
+// The points are projected onto a plane around a, which is accurate for the short distances between reports.
+func segmentDistanceMeters(p, a, b [2]float64) float64 {
+	metersPerDegree := earthRadiusMeters * math.Pi / 180
+	longScale := metersPerDegree * math.Cos(a[1]*math.Pi/180)
+	px, py := (p[0]-a[0])*longScale, (p[1]-a[1])*metersPerDegree
+	bx, by := (b[0]-a[0])*longScale, (b[1]-a[1])*metersPerDegree
+	lengthSquared := bx*bx + by*by
+	if lengthSquared == 0 {
+		return math.Hypot(px, py)
+	}
+	// How far along the segment the closest point is, from 0 at a to 1 at b
+	along := math.Max(0, math.Min(1, (px*bx+py*by)/lengthSquared))
+	return math.Hypot(px-along*bx, py-along*by)
+}
+
+// ConnectionBreakdown estimates how long the device spent on each connection Method (Cellular, Wi-Fi or Not connected) during the day.
+// The time between consecutive snapshots is attributed to the connection of the earlier one.
+// Snapshots without a time or connection are skipped, so their interval is attributed to the previous snapshot that has both.
+func (d *Day) ConnectionBreakdown() map[string]time.Duration {
+	snapshots := append([]Snapshot(nil), d.Snapshots...)
+	sortSnapshots(snapshots)
+	breakdown := make(map[string]time.Duration)
+	var previous *Snapshot
+	var previousTime time.Time
+	for i := range snapshots {
+		snapshot := &snapshots[i]
+		snapshotTime, ok := snapshot.EffectiveTime()
+		if !ok || snapshot.Connection == nil || snapshot.Connection.Method == "" {
+			continue
+		}
+		if previous != nil {
+			breakdown[previous.Connection.Method] += snapshotTime.Sub(previousTime)
+		}
+		previous, previousTime = snapshot, snapshotTime
+	}
+	return breakdown
+}
+
+// Intervals returns the shortest, longest and median gaps between consecutive snapshot times, showing how regularly reports were made.
+// The median of an even number of gaps is the mean of the middle two. ok is false if fewer than two snapshots have a time.
+func (d *Day) Intervals() (min, max, median time.Duration, ok bool) {
+	var times []time.Time
+	for _, snapshot := range d.Snapshots {
+		if snapshotTime, hasTime := snapshot.EffectiveTime(); hasTime {
+			times = append(times, snapshotTime)
+		}
+	}
+	if len(times) < 2 {
+		return 0, 0, 0, false
+	}
+	sortTimes(times)
+	gaps := make([]time.Duration, len(times)-1)
+	for i := range gaps {
+		gaps[i] = times[i+1].Sub(times[i])
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+	middle := len(gaps) / 2
+	median = gaps[middle]
+	if len(gaps)%2 == 0 {
+		median = (gaps[middle-1] + gaps[middle]) / 2
+	}
+	return gaps[0], gaps[len(gaps)-1], median, true
+}
+
+// Diff returns human readable descriptions of the differences between the day and other, such as
+//
+//	snapshot 1D8A7A8E-...: weather.tempC changed from 16.6 to 17
+//
+// Snapshots are matched by uniqueIdentifier, or by position for snapshots without one (schema version 1).
+// Fields are compared by their JSON representation using the schema version of the day, so Date and FileInfo are not compared.
+// An empty result means the days are equivalent.
+func (d *Day) Diff(other Day) []string {
+	var diffs []string
+	if d.SchemaVersion != other.SchemaVersion {
+		diffs = append(diffs, fmt.Sprintf("schema version changed from %d to %d", d.SchemaVersion, other.SchemaVersion))
+	}
+	withoutSnapshots := func(day Day) interface{} {
+		day.Snapshots = nil
+		return day
+	}
+	dayDiffs, err := diffJSON("", withoutSnapshots(*d), withoutSnapshots(other), d.SchemaVersion)
+	if err != nil {
+		return append(diffs, err.Error())
+	}
+	diffs = append(diffs, dayDiffs...)
+
+	key := func(i int, snapshot Snapshot) string {
+		if snapshot.ID != "" {
+			return snapshot.ID
+		}
+		return fmt.Sprintf("#%d", i)
+	}
+	otherSnapshots := make(map[string]Snapshot)
+	for i, snapshot := range other.Snapshots {
+		otherSnapshots[key(i, snapshot)] = snapshot
+	}
+	seen := make(map[string]bool)
+	for i, snapshot := range d.Snapshots {
+		id := key(i, snapshot)
+		seen[id] = true
+		otherSnapshot, ok := otherSnapshots[id]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("snapshot %s removed", id))
+			continue
+		}
+		snapshotDiffs, err := diffJSON("snapshot "+id+": ", snapshot, otherSnapshot, d.SchemaVersion)
+		if err != nil {
+			return append(diffs, err.Error())
+		}
+		diffs = append(diffs, snapshotDiffs...)
+	}
+	for i, snapshot := range other.Snapshots {
+		if id := key(i, snapshot); !seen[id] {
+			diffs = append(diffs, fmt.Sprintf("snapshot %s added", id))
+		}
+	}
+	return diffs
+}
+
+// MostVisitedPlacemark returns the placemark reported by the most snapshots along with the number of snapshots that reported it.
+// Placemarks are considered the same if they share a Locality and Name. Ties resolve to the placemark seen first,
+// and the placemark of the first snapshot that reported it is returned. ok will be false if no snapshot has a placemark.
+func (d *Day) MostVisitedPlacemark() (placemark *Placemark, count int, ok bool) {
+	type visits struct {
+		placemark *Placemark
+		count     int
+	}
+	var order []string
+	counts := make(map[string]*visits)
+	for _, snapshot := range d.Snapshots {
+		if snapshot.Location == nil || snapshot.Location.Placemark == nil {
+			continue
+		}
+		key := snapshot.Location.Placemark.Locality + "\x00" + snapshot.Location.Placemark.Name
+		if counts[key] == nil {
+			counts[key] = &visits{placemark: snapshot.Location.Placemark}
+			order = append(order, key)
+		}
+		counts[key].count++
+	}
+	for _, key := range order {
+		if counts[key].count > count {
+			placemark, count, ok = counts[key].placemark, counts[key].count, true
+		}
+	}
+	return
+}
+
+// TemperatureExtremes returns the snapshots with the highest and lowest TemperatureCelsius, which point into d.Snapshots.
+// Ties resolve to the snapshot that comes first. ok will be false if no snapshot has a temperature.
+func (d *Day) TemperatureExtremes() (hottest, coldest *Snapshot, ok bool) {
+	var highest, lowest float64
+	for i := range d.Snapshots {
+		temperature, hasTemperature := d.Snapshots[i].TemperatureCelsius()
+		if !hasTemperature {
+			continue
+		}
+		if !ok || temperature > highest {
+			hottest, highest = &d.Snapshots[i], temperature
+		}
+		if !ok || temperature < lowest {
+			coldest, lowest = &d.Snapshots[i], temperature
+		}
+		ok = true
+	}
+	return
+}
+
+// Tags returns every token and answered option across the responses of the day as a flat list of tags.
+// Tags are trimmed and de-duplicated case-insensitively, keeping the casing they were first seen with.
+// The most frequently reported tags come first, with ties sorted alphabetically.
+func (d *Day) Tags() []string {
+	var tags []string
+	counts := make(map[string]int)
+	add := func(tag string) {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			return
+		}
+		key := strings.ToLower(tag)
+		if counts[key] == 0 {
+			tags = append(tags, tag)
+		}
+		counts[key]++
+	}
+	for _, snapshot := range d.Snapshots {
+		for _, response := range snapshot.Responses {
+			if response == nil {
+				continue
+			}
+			for _, token := range response.Tokens {
+				if token != nil {
+					add(token.Text)
+				}
+			}
+			for _, option := range response.AnsweredOptions {
+				add(option)
+			}
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		iKey, jKey := strings.ToLower(tags[i]), strings.ToLower(tags[j])
+		if counts[iKey] != counts[jKey] {
+			return counts[iKey] > counts[jKey]
+		}
+		return iKey < jKey
+	})
+	return tags
+}
+
+// AverageHorizontalAccuracy returns the mean HorizontalAccuracy in meters of the located snapshots, as a measure of how reliable the day's GPS fixes were.
+// Lower is better. Negative accuracies, which CoreLocation uses to mark invalid fixes, are left out.
+// ok will be false if no snapshot has a valid horizontal accuracy.
+func (d *Day) AverageHorizontalAccuracy() (meters float64, ok bool) {
+	var total float64
+	count := 0
+	for _, snapshot := range d.Snapshots {
+		if snapshot.Location == nil || snapshot.Location.HorizontalAccuracy == nil || *snapshot.Location.HorizontalAccuracy < 0 {
+			continue
+		}
+		total += *snapshot.Location.HorizontalAccuracy
+		count++
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return total / float64(count), true
+}
+
+// timeSeriesMetrics are the metrics available to TimeSeries, keyed by name
+var timeSeriesMetrics = map[string]func(*Snapshot) (float64, bool){
+	"battery": (*Snapshot).BatteryFraction,
+	"steps": func(s *Snapshot) (float64, bool) {
+		if s.Steps == nil || *s.Steps < 0 {
+			return 0, false
+		}
+		return float64(*s.Steps), true
+	},
+	"tempC":      (*Snapshot).TemperatureCelsius,
+	"tempF":      (*Snapshot).TemperatureFarenheit,
+	"audioAvg":   (*Snapshot).AudioAverageDb,
+	"audioPeak":  (*Snapshot).AudioPeakDb,
+	"pressureMb": (*Snapshot).PressureMillibars,
+}
+
+// timeSeriesMetric returns the function reading the named metric from a snapshot, or an error listing the valid metrics
+func timeSeriesMetric(metric string) (func(*Snapshot) (float64, bool), error) {
+	value, ok := timeSeriesMetrics[metric]
+	if !ok {
+		var names []string
+		for name := range timeSeriesMetrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("Unknown metric %q, valid metrics are %s", metric, strings.Join(names, ", "))
+	}
+	return value, nil
+}
+
+// TimeSeries returns parallel slices of times and values of the named metric, in time order, ready to be plotted.
+// The metrics are battery, steps, tempC, tempF, audioAvg, audioPeak and pressureMb.
+// Snapshots without a time or the metric are skipped. An unknown metric returns an error listing the valid ones.
+func (d *Day) TimeSeries(metric string) ([]time.Time, []float64, error) {
+	value, err := timeSeriesMetric(metric)
+	if err != nil {
+		return nil, nil, err
+	}
+	snapshots := append([]Snapshot(nil), d.Snapshots...)
+	sortSnapshots(snapshots)
+	var times []time.Time
+	var values []float64
+	for i := range snapshots {
+		snapshotTime, hasTime := snapshots[i].EffectiveTime()
+		if !hasTime {
+			continue
+		}
+		if v, hasMetric := value(&snapshots[i]); hasMetric {
+			times = append(times, snapshotTime)
+			values = append(values, v)
+		}
+	}
+	return times, values, nil
+}
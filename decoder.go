@@ -0,0 +1,252 @@
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Geocoder reverse geocodes a latitude/longitude into a Placemark
+type Geocoder interface {
+	Reverse(lat, long float64) (*Placemark, error)
+}
+
+// rateLimitedGeocoder is a Geocoder that spaces out calls to another Geocoder
+type rateLimitedGeocoder struct {
+	geocoder Geocoder
+	interval time.Duration
+
+	mutex sync.Mutex
+	next  time.Time // The earliest time the next call may start
+}
+
+// RateLimitedGeocoder returns a Geocoder that calls g at most rps times per second, making callers wait their turn.
+// Calls made from several goroutines, such as Decoders sharing the returned Geocoder, share the same limit,
+// and may reach g concurrently once their turns come, so g must also be safe for concurrent use.
+// If rps isn't greater than 0, g is returned unchanged.
+func RateLimitedGeocoder(g Geocoder, rps float64) Geocoder {
+	if rps <= 0 {
+		return g
+	}
+	return &rateLimitedGeocoder{geocoder: g, interval: time.Duration(float64(time.Second) / rps)}
+}
+
+// Reverse waits until the rate limit allows another call and then reverse geocodes using the wrapped Geocoder
+func (r *rateLimitedGeocoder) Reverse(lat, long float64) (*Placemark, error) {
+	r.mutex.Lock()
+	now := time.Now()
+	start := r.next
+	if start.Before(now) {
+		start = now
+	}
+	r.next = start.Add(r.interval)
+	r.mutex.Unlock()
+	time.Sleep(time.Until(start))
+	return r.geocoder.Reverse(lat, long)
+}
+
+// DecodeOptions control how a Decoder turns JSON into a Day
+type DecodeOptions struct {
+	SchemaVersion int            // If set, recorded on every decoded Day instead of the detected schema version
+	Location      *time.Location // If set, every snapshot timestamp is converted to this location
+	Strict        bool           // Rejects JSON with fields that aren't part of the schema, at any depth, instead of capturing or ignoring them
+	Geocoder      Geocoder       // If set, used to fill in the Placemark of located snapshots that don't have one
+	SkipPhotos    bool           // Leaves PhotoSet nil instead of decoding the EXIF data of every photo
+	SkipWeather   bool           // Leaves Weather nil
+	SkipResponses bool           // Leaves Responses nil
+	// FillWeatherUnits calls Weather.FillDerivedUnits on every snapshot so both metric and imperial weather fields are set.
+	// It is off by default so decoded days match the JSON exactly.
+	FillWeatherUnits bool
+	// KeepRaw stores a copy of the decoded JSON on Day.Raw, which Day.JSON then returns verbatim for byte-perfect re-export.
+	KeepRaw bool
+	// InferDateFromFilename sets the Date of snapshots that don't have one to midnight of the day in the filename when decoding a File,
+	// in Location if it is set. The inferred dates only have the calendar day and are written out when the day is marshaled.
+	InferDateFromFilename bool
+}
+
+// A Decoder decodes Reporter JSON into Days using the same DecodeOptions every time.
+// A Decoder may be shared between goroutines and decodes reports in parallel, as long as its Geocoder can be used concurrently.
+// The schema version is detected from the JSON itself, so decoding doesn't depend on SchemaVersion;
+// SchemaVersion is only set to the version of the last decoded report once it has been decoded.
+type Decoder struct {
+	Options DecodeOptions
+}
+
+// defaultDecoder is the Decoder used by the package level decoding functions
+var defaultDecoder = &Decoder{}
+
+// NewDecoder returns a Decoder that decodes using the given options
+func NewDecoder(opts DecodeOptions) *Decoder {
+	return &Decoder{Options: opts}
+}
+
+// Decode returns a Day for raw JSON
+func (dec *Decoder) Decode(b []byte) (Day, error) {
+	day, err := dec.decodeBytes(b)
+	if err != nil {
+		return day, err
+	}
+	return day, dec.apply(&day)
+}
+
+// DecodeFile returns a Day for a given File, recording the File (without its contents) on the Day
+func (dec *Decoder) DecodeFile(file File) (Day, error) {
+	day, err := dec.decodeBytes([]byte(file.Contents))
+	if err != nil {
+		return day, err
+	}
+	file.Contents = ""
+	day.FileInfo = file
+	day.Date = file.TimeFromFilename
+	return day, dec.apply(&day)
+}
+
+// apply applies the decoder's options to a freshly decoded day
+func (dec *Decoder) apply(day *Day) error {
+	opts := dec.Options
+	if opts.SchemaVersion != 0 {
+		day.SchemaVersion = opts.SchemaVersion
+	}
+	for i := range day.Snapshots {
+		snapshot := &day.Snapshots[i]
+		if opts.Location != nil {
+			if snapshot.Date != nil {
+				snapshot.Date.Time = snapshot.Date.In(opts.Location)
+			}
+			if snapshot.Day != nil {
+				snapshot.Day.Time = snapshot.Day.In(opts.Location)
+			}
+		}
+		if opts.InferDateFromFilename && snapshot.Date == nil && !day.Date.IsZero() {
+			location := opts.Location
+			if location == nil {
+				location = time.UTC
+			}
+			year, month, date := day.Date.Date()
+			snapshot.Date = &DateTime{Time: time.Date(year, month, date, 0, 0, 0, 0, location)}
+		}
+		if opts.FillWeatherUnits && snapshot.Weather != nil {
+			snapshot.Weather.FillDerivedUnits()
+		}
+		if opts.Geocoder != nil && snapshot.Location != nil && snapshot.Location.Placemark == nil {
+			if lat, long, ok := snapshot.Coordinates(); ok {
+				placemark, err := opts.Geocoder.Reverse(lat, long)
+				if err != nil {
+					return fmt.Errorf("Unable to geocode snapshot %d: %w", i, err)
+				}
+				snapshot.Location.Placemark = placemark
+			}
+		}
+	}
+	return nil
+}
+
+// unknownFieldsError returns an error listing the fields anywhere in the JSON of a day that aren't part of the schema, if there are any
+func unknownFieldsError(b []byte) error {
+	fields := unknownSchemaFields(b, reflect.TypeOf(Day{}), "")
+	if len(fields) == 0 {
+		return nil
+	}
+	sort.Strings(fields)
+	return fmt.Errorf("Unknown fields: %s", strings.Join(fields, ", "))
+}
+
+// ErrEmptyReport is returned when decoding a report that is empty or only contains whitespace
+var ErrEmptyReport = errors.New("Report is empty")
+
+// ErrTrailingData is returned when a report has more data after the end of its JSON, such as the stray } left behind by a truncated Dropbox sync
+var ErrTrailingData = errors.New("Unexpected data after the end of the report")
+
+// utf8BOM is the byte order mark some editors and sync tools put at the start of UTF-8 files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// ErrAmbiguousSchema is returned by DetectSchemaVersion when nothing in a report distinguishes schema version 1 from 2
+var ErrAmbiguousSchema = errors.New("Unable to tell the schema version of the report")
+
+// DefaultSchemaVersion is the schema version recorded for reports that don't contain anything distinguishing the versions,
+// such as timestamps or tokens
+const DefaultSchemaVersion = 2
+
+// DetectSchemaVersion decodes raw JSON and returns the schema version it was written with.
+// If nothing in the JSON distinguishes the versions, DefaultSchemaVersion is returned along with ErrAmbiguousSchema,
+// so callers can decide which version to use. Decoding the same JSON records DefaultSchemaVersion on the Day.
+func DetectSchemaVersion(b []byte) (int, error) {
+	day, detected, err := defaultDecoder.decodeDetecting(b)
+	if err != nil {
+		return 0, err
+	}
+	if !detected {
+		return day.SchemaVersion, ErrAmbiguousSchema
+	}
+	return day.SchemaVersion, nil
+}
+
+// decodeBytes returns a Day for raw JSON, recording the detected schema version on it, or DefaultSchemaVersion if it can't be detected.
+// A leading UTF-8 byte order mark and trailing whitespace and NULs are ignored.
+// The snapshot fields selected by the Skip options are discarded while decoding.
+func (dec *Decoder) decodeBytes(b []byte) (Day, error) {
+	day, _, err := dec.decodeDetecting(b)
+	return day, err
+}
+
+// decodeDetecting is decodeBytes, also returning whether anything in the JSON revealed its schema version
+func (dec *Decoder) decodeDetecting(b []byte) (Day, bool, error) {
+	var day Day
+	body := bytes.TrimRight(bytes.TrimPrefix(b, utf8BOM), " \t\r\n\x00")
+	if len(bytes.TrimSpace(body)) == 0 {
+		return day, false, ErrEmptyReport
+	}
+	if dec.Options.Strict {
+		if err := unknownFieldsError(body); err != nil {
+			return day, false, err
+		}
+	}
+	err := day.unmarshalJSON(body, fieldSkips{dec.Options.SkipPhotos, dec.Options.SkipWeather, dec.Options.SkipResponses})
+	if err != nil {
+		if trailingErr := trailingDataError(body, len(b)-len(bytes.TrimPrefix(b, utf8BOM))); trailingErr != nil {
+			return day, false, trailingErr
+		}
+		return day, false, err
+	}
+	// The version is detected from the JSON instead of the package SchemaVersion, so reports can be decoded in parallel
+	day.SchemaVersion = schemaVersionOf(body, reflect.TypeOf(Day{}))
+	detected := day.SchemaVersion != 0
+	if !detected {
+		day.SchemaVersion = DefaultSchemaVersion
+	}
+	// SchemaVersion is still updated afterwards for callers that marshal with json.Marshal, which can't see the Day's version
+	decodeMutex.Lock()
+	SchemaVersion = day.SchemaVersion
+	decodeMutex.Unlock()
+	if dec.Options.KeepRaw {
+		day.Raw = append([]byte(nil), b...)
+	}
+	return day, detected, nil
+}
+
+// trailingDataError returns an error wrapping ErrTrailingData that shows where the data after the first JSON value in b starts,
+// or nil if b doesn't hold a complete JSON value followed by more data. offset is added to the reported position to account for
+// anything stripped from the start of b.
+func trailingDataError(b []byte, offset int) error {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	var value json.RawMessage
+	if err := decoder.Decode(&value); err != nil {
+		return nil
+	}
+	end := int(decoder.InputOffset())
+	rest := bytes.TrimLeft(b[end:], " \t\r\n")
+	if len(rest) == 0 {
+		return nil
+	}
+	position := offset + len(b) - len(rest)
+	if len(rest) > 16 {
+		rest = rest[:16]
+	}
+	return fmt.Errorf("%w at byte %d: %q", ErrTrailingData, position, rest)
+}
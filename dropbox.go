@@ -1,65 +1,120 @@
 package reporter
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/stacktic/dropbox"
 )
 
+// DropboxDefaultLocations are the folders different versions of the app have written reports to.
+// They are tried in order when a DropboxBackend has no StorageLocation.
+var DropboxDefaultLocations = []string{"/Apps/Reporter-App/", "/Apps/Reporter/"}
+
 // DropboxBackend is a struct that stores the Dropbox client and default report storage location
 type DropboxBackend struct {
 	*dropbox.Dropbox
-	StorageLocation string // The absolute path to the location of the Reporter JSON, usually /Apps/Reporter-App/
+	StorageLocation string // The absolute path to the location of the Reporter JSON, usually /Apps/Reporter-App/. If empty, it is detected from DropboxDefaultLocations.
+	MaxReportBytes  int64  // The largest report that will be downloaded. If 0, DefaultMaxReportBytes is used.
+	Logger          Logger // Receives diagnostic messages. If nil, nothing is logged.
+
+	locationMutex sync.Mutex
+}
+
+// storageLocation returns the StorageLocation, first detecting it if it is empty.
+// The first of the DropboxDefaultLocations that contains reports is used and stored as the StorageLocation.
+func (db *DropboxBackend) storageLocation() (string, error) {
+	db.locationMutex.Lock()
+	defer db.locationMutex.Unlock()
+	if db.StorageLocation != "" {
+		return db.StorageLocation, nil
+	}
+	for _, location := range DropboxDefaultLocations {
+		metadata, err := db.Metadata(location, true, false, "", "", 10000)
+		if err != nil {
+			continue
+		}
+		for _, file := range metadata.Contents {
+			if isReportFilename(file.Path) {
+				db.StorageLocation = location
+				return location, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("No reports found in any of the default Dropbox locations (%s)", strings.Join(DropboxDefaultLocations, ", "))
 }
 
 // GetLatestReport searches the storageLocation to find the latest report file.
 // It searches based on filename, not on modified or created time, because
 // both can be updated after/before the date in the filename.
+// Empty files, such as those still being uploaded, are skipped.
 func (db *DropboxBackend) GetLatestReport() (File, error) {
 	var reporterFile File
-	metadata, err := db.Metadata(db.StorageLocation, true, false, "", "", 10000)
+	location, err := db.storageLocation()
+	if err != nil {
+		return reporterFile, err
+	}
+	metadata, err := db.Metadata(location, true, false, "", "", 10000)
 	if err != nil {
 		return reporterFile, err
 	}
-	var newestTime time.Time
+	var newestTime, newestModified time.Time
 	var newestPath string
 	for _, file := range metadata.Contents {
-		if strings.Contains(filepath.Base(file.Path), "-reporter-export.json") {
+		if isReportFilename(file.Path) && file.Bytes > 0 {
 			filenameDate, err := dateForFilename(file.Path)
 			if err != nil {
-				return reporterFile, err
+				loggerOrNop(db.Logger).Errorf("Skipping %s, unable to parse the date from its filename: %v", file.Path, err)
+				continue
 			}
-			if filenameDate.After(newestTime) {
+			modified := time.Time(file.Modified)
+			if newestPath == "" || filenameDate.After(newestTime) || (filenameDate.Equal(newestTime) && modified.After(newestModified)) {
 				newestTime = filenameDate
+				newestModified = modified
 				newestPath = file.Path
 			}
 		}
 	}
-
+	if newestPath == "" {
+		return reporterFile, errors.New("No reports found in Dropbox")
+	}
 	return db.GetReportForPath(newestPath)
 }
 
 // GetReportForPath returns a File for the file at the full path specified.
 func (db *DropboxBackend) GetReportForPath(filePath string) (File, error) {
 	var reporterFile File
+	start := time.Now()
 	reader, _, err := db.Download(filePath, "", 0)
 	if err != nil {
-		return reporterFile, err
+		return reporterFile, dropboxError(filePath, err)
 	}
 	defer reader.Close()
-	file, readErr := ioutil.ReadAll(reader)
+	file, readErr := readReport(reader, db.MaxReportBytes)
 	if readErr != nil {
+		loggerOrNop(db.Logger).Errorf("Unable to download %s: %v", filePath, readErr)
 		return reporterFile, readErr
 	}
+	loggerOrNop(db.Logger).Debugf("Downloaded %s (%d bytes) in %s", filePath, len(file), time.Since(start))
 
 	metadata, err := db.Metadata(filePath, false, false, "", "", 1)
-	if readErr != nil {
-		return reporterFile, readErr
+	if err != nil {
+		return reporterFile, fmt.Errorf("Unable to get metadata for %s: %w", filePath, dropboxError(filePath, err))
+	}
+	if metadata.IsDeleted {
+		return reporterFile, fmt.Errorf("%s: %w", filePath, ErrReportNotFound)
 	}
 
 	filenameDate, err := dateForFilename(filePath)
@@ -73,56 +128,317 @@ func (db *DropboxBackend) GetReportForPath(filePath string) (File, error) {
 		Source:           "dropbox",
 		ModifiedTime:     time.Time(metadata.Modified),
 		TimeFromFilename: filenameDate,
+		ConflictedCopy:   isConflictedCopy(filePath),
 		Contents:         string(file),
 	}, nil
 }
 
+// OpenReport returns the download of the file at the full path specified, which the caller must close.
+// ctx is only checked before the download starts, since the Dropbox client can't cancel a download.
+func (db *DropboxBackend) OpenReport(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	reader, _, err := db.Download(filePath, "", 0)
+	if err != nil {
+		return nil, dropboxError(filePath, err)
+	}
+	return reader, nil
+}
+
+// dropboxError wraps ErrReportNotFound with the file path if err is a Dropbox not found response, and returns any other error unchanged
+func dropboxError(filePath string, err error) error {
+	var apiErr *dropbox.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s: %w", filePath, ErrReportNotFound)
+	}
+	return err
+}
+
 // GetReportForTime returns a File for the file with the date given in the filename
 func (db *DropboxBackend) GetReportForTime(date time.Time) (File, error) {
-	filePath := fmt.Sprintf("%s%s-reporter-export.json", db.StorageLocation, date.Format("2006-01-02"))
+	filePath, err := db.reportPath(filenameForDate(date))
+	if err != nil {
+		return File{}, err
+	}
 	return db.GetReportForPath(filePath)
 }
 
 // ListReports lists all available reports
 func (db *DropboxBackend) ListReports() ([]File, error) {
 	var allFiles []File
-	metadata, err := db.Metadata(db.StorageLocation, true, false, "", "", 10000)
+	location, err := db.storageLocation()
+	if err != nil {
+		return allFiles, err
+	}
+	metadata, err := db.Metadata(location, true, false, "", "", 10000)
 	if err != nil {
 		return allFiles, err
 	}
 	for _, file := range metadata.Contents {
-		if strings.Contains(filepath.Base(file.Path), "-reporter-export.json") {
-			filenameDate, err := dateForFilename(file.Path)
+		if !isReportFilename(file.Path) {
+			loggerOrNop(db.Logger).Debugf("Skipping %s, it is not a report", file.Path)
+			continue
+		}
+		filenameDate, err := dateForFilename(file.Path)
+		if err != nil {
+			loggerOrNop(db.Logger).Errorf("Skipping %s, unable to parse the date from its filename: %v", file.Path, err)
+			continue
+		}
+		allFiles = append(allFiles, File{
+			Name:             filepath.Base(file.Path),
+			Path:             file.Path,
+			Source:           "dropbox",
+			ModifiedTime:     time.Time(file.Modified),
+			TimeFromFilename: filenameDate,
+			ConflictedCopy:   isConflictedCopy(file.Path),
+		})
+	}
+
+	return allFiles, nil
+}
+
+// ListReportsDelta lists the reports added or modified in the StorageLocation since cursor was returned, along with a new cursor to persist for the next call.
+// Pass an empty cursor to list every report and get an initial cursor. Deleted reports are not returned, and a report changed more than once is only returned once.
+// To wait for changes instead of polling, call LongPollDelta with the cursor before calling ListReportsDelta again.
+func (db *DropboxBackend) ListReportsDelta(cursor string) ([]File, string, error) {
+	location, err := db.storageLocation()
+	if err != nil {
+		return nil, cursor, err
+	}
+	return listReportsDelta(db.Delta, path.Clean(location), cursor, db.Logger)
+}
+
+// listReportsDelta is ListReportsDelta, reading the pages of changes under location from delta
+func listReportsDelta(delta func(cursor, pathPrefix string) (*dropbox.DeltaPage, error), location, cursor string, logger Logger) ([]File, string, error) {
+	var changedFiles []File
+	for {
+		page, err := delta(cursor, location)
+		if err != nil {
+			return changedFiles, cursor, err
+		}
+		if page.Reset {
+			changedFiles = nil
+		}
+		for _, entry := range page.Entries {
+			// A later entry for the same path replaces an earlier one, and Dropbox lowercases entry paths, so compare case-insensitively
+			kept := changedFiles[:0]
+			for _, file := range changedFiles {
+				if !strings.EqualFold(file.Path, entry.Path) {
+					kept = append(kept, file)
+				}
+			}
+			changedFiles = kept
+			if entry.Entry == nil || entry.Entry.IsDir || !isReportFilename(entry.Entry.Path) {
+				continue
+			}
+			filenameDate, err := dateForFilename(entry.Entry.Path)
 			if err != nil {
-				return allFiles, err
+				loggerOrNop(logger).Errorf("Skipping %s, unable to parse the date from its filename: %v", entry.Entry.Path, err)
+				continue
 			}
-			allFiles = append(allFiles, File{
-				Name:             filepath.Base(file.Path),
-				Path:             file.Path,
+			changedFiles = append(changedFiles, File{
+				Name:             filepath.Base(entry.Entry.Path),
+				Path:             entry.Entry.Path,
 				Source:           "dropbox",
-				ModifiedTime:     time.Time(file.Modified),
+				ModifiedTime:     time.Time(entry.Entry.Modified),
 				TimeFromFilename: filenameDate,
+				ConflictedCopy:   isConflictedCopy(entry.Entry.Path),
 			})
 		}
+		cursor = page.Cursor.Cursor
+		if !page.HasMore {
+			return changedFiles, cursor, nil
+		}
 	}
+}
 
-	return allFiles, nil
+// SaveReport uploads the contents of the File to the StorageLocation using the File's name, overwriting any existing report.
+// If there is no StorageLocation and none of the DropboxDefaultLocations contain reports yet, the first default location is used.
+func (db *DropboxBackend) SaveReport(file File) error {
+	filePath, err := db.reportPath(file.Name)
+	if err != nil {
+		filePath = path.Join(DropboxDefaultLocations[0], file.Name)
+	}
+	contents := ioutil.NopCloser(strings.NewReader(file.Contents))
+	_, err = db.FilesPut(contents, int64(len(file.Contents)), filePath, true, "")
+	return err
+}
+
+// reportPath joins the StorageLocation, detecting it if needed, and a report name with exactly one separator,
+// regardless of whether StorageLocation has a trailing slash.
+func (db *DropboxBackend) reportPath(name string) (string, error) {
+	location, err := db.storageLocation()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(location, name), nil
 }
 
 // NewDropboxBackend returns a new Dropbox backend to read JSON from.
 // You must provide an accessToken, which you can get by creating an app
 // in the Dropbox API and then pressing Generate.
 // Access tokens do not expire.
-// If a storageLocation isn't provided, the default location is
-//   /Apps/Reporter-App/
+// If a storageLocation isn't provided, it is detected from the DropboxDefaultLocations,
+// which are the folders current and older versions of the app write to:
+//
+//	/Apps/Reporter-App/
+//	/Apps/Reporter/
 func NewDropboxBackend(accessToken, storageLocation string) (*DropboxBackend, error) {
 	if accessToken == "" {
 		return nil, errors.New("No access token provided for Dropbox backend")
 	}
 	db := dropbox.NewDropbox()
 	db.SetAccessToken(accessToken)
-	if storageLocation == "" {
-		storageLocation = "/Apps/Reporter-App/"
+	return &DropboxBackend{Dropbox: db, StorageLocation: storageLocation}, nil
+}
+
+// DropboxSharedLinkBackend reads reports from a public Dropbox shared folder link, without needing access to the whole account.
+// Dropbox serves a shared folder as a zip archive, so every call downloads the archive of the folder.
+type DropboxSharedLinkBackend struct {
+	SharedLink     string       // The shared link of the folder containing the Reporter JSON
+	Client         *http.Client // The HTTP client used to download the folder. If nil, http.DefaultClient is used.
+	MaxReportBytes int64        // The largest report that will be read from the folder. If 0, DefaultMaxReportBytes is used.
+	Logger         Logger       // Receives diagnostic messages. If nil, nothing is logged.
+}
+
+// download fetches the zip archive of the shared folder
+func (db *DropboxSharedLinkBackend) download() (*zip.Reader, error) {
+	start := time.Now()
+	link, err := url.Parse(db.SharedLink)
+	if err != nil {
+		return nil, err
+	}
+	query := link.Query()
+	query.Set("dl", "1")
+	link.RawQuery = query.Encode()
+	client := db.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Get(link.String())
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unable to download Dropbox shared link, got status %s", response.Status)
+	}
+	archive, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		loggerOrNop(db.Logger).Errorf("Unable to download Dropbox shared link: %v", err)
+		return nil, err
+	}
+	loggerOrNop(db.Logger).Debugf("Downloaded Dropbox shared link (%d bytes) in %s", len(archive), time.Since(start))
+	return zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+}
+
+// GetLatestReport searches the shared folder to find the latest report file.
+// It searches based on filename, not on modified or created time, because
+// both can be updated after/before the date in the filename.
+func (db *DropboxSharedLinkBackend) GetLatestReport() (File, error) {
+	var reporterFile File
+	files, err := db.ListReports()
+	if err != nil {
+		return reporterFile, err
+	}
+	newest, ok := latestReport(files)
+	if !ok {
+		return reporterFile, errors.New("No reports found in Dropbox shared link")
+	}
+	return db.GetReportForPath(newest.Path)
+}
+
+// GetReportForPath returns a File for the file at the path specified, relative to the shared folder.
+func (db *DropboxSharedLinkBackend) GetReportForPath(filePath string) (File, error) {
+	var reporterFile File
+	archive, err := db.download()
+	if err != nil {
+		return reporterFile, err
+	}
+	for _, entry := range archive.File {
+		if path.Clean("/"+entry.Name) != path.Clean("/"+filePath) {
+			continue
+		}
+		reader, err := entry.Open()
+		if err != nil {
+			return reporterFile, err
+		}
+		defer reader.Close()
+		file, err := readReport(reader, db.MaxReportBytes)
+		if err != nil {
+			return reporterFile, err
+		}
+		filenameDate, err := dateForFilename(entry.Name)
+		if err != nil {
+			return reporterFile, err
+		}
+		return File{
+			Name:             path.Base(entry.Name),
+			Path:             entry.Name,
+			Source:           "dropbox",
+			ModifiedTime:     entry.Modified,
+			TimeFromFilename: filenameDate,
+			ConflictedCopy:   isConflictedCopy(entry.Name),
+			Contents:         string(file),
+		}, nil
+	}
+	return reporterFile, fmt.Errorf("%s not found in Dropbox shared link: %w", filePath, ErrReportNotFound)
+}
+
+// GetReportForTime returns a File for the file with the date given in the filename
+func (db *DropboxSharedLinkBackend) GetReportForTime(date time.Time) (File, error) {
+	var reporterFile File
+	files, err := db.ListReports()
+	if err != nil {
+		return reporterFile, err
+	}
+	for _, file := range files {
+		if file.Name == filenameForDate(date) {
+			return db.GetReportForPath(file.Path)
+		}
+	}
+	return reporterFile, fmt.Errorf("%s not found in Dropbox shared link: %w", filenameForDate(date), ErrReportNotFound)
+}
+
+// ListReports lists all available reports
+func (db *DropboxSharedLinkBackend) ListReports() ([]File, error) {
+	var allFiles []File
+	archive, err := db.download()
+	if err != nil {
+		return allFiles, err
+	}
+	for _, entry := range archive.File {
+		if !isReportFilename(entry.Name) {
+			loggerOrNop(db.Logger).Debugf("Skipping %s, it is not a report", entry.Name)
+			continue
+		}
+		filenameDate, err := dateForFilename(entry.Name)
+		if err != nil {
+			loggerOrNop(db.Logger).Errorf("Skipping %s, unable to parse the date from its filename: %v", entry.Name, err)
+			continue
+		}
+		allFiles = append(allFiles, File{
+			Name:             path.Base(entry.Name),
+			Path:             entry.Name,
+			Source:           "dropbox",
+			ModifiedTime:     entry.Modified,
+			TimeFromFilename: filenameDate,
+			ConflictedCopy:   isConflictedCopy(entry.Name),
+		})
+	}
+	return allFiles, nil
+}
+
+// NewDropboxSharedLinkBackend returns a new Dropbox backend that reads JSON from a shared folder link,
+// i.e. https://www.dropbox.com/sh/abc123/AAAbbbCCC?dl=0
+// Only read access to the shared folder is needed.
+func NewDropboxSharedLinkBackend(sharedLink string) (*DropboxSharedLinkBackend, error) {
+	if sharedLink == "" {
+		return nil, errors.New("No shared link provided for Dropbox shared link backend")
+	}
+	if _, err := url.Parse(sharedLink); err != nil {
+		return nil, err
 	}
-	return &DropboxBackend{db, storageLocation}, nil
+	return &DropboxSharedLinkBackend{SharedLink: sharedLink}, nil
 }
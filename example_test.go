@@ -48,3 +48,16 @@ func ExampleNewDropboxBackend() {
 	}
 	fmt.Print(day)
 }
+
+// This example loads and decodes the latest report from a filesystem backend in one call.
+func ExampleLatestDay() {
+	backend, err := reporter.NewFilesystemBackend("")
+	if err != nil {
+		fmt.Print(err)
+	}
+	day, err := reporter.LatestDay(backend)
+	if err != nil {
+		fmt.Print(err)
+	}
+	fmt.Print(day)
+}
@@ -0,0 +1,187 @@
+package reporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// influxMeasurementEscaper escapes the characters that are special in an InfluxDB line protocol measurement name
+var influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+
+// WriteInfluxLine writes one InfluxDB line protocol record per snapshot to w, using the snapshot's EffectiveTime in nanoseconds as the timestamp.
+// Each record has battery (as a BatteryFraction), steps, audio_avg, audio_peak and temp_c fields. Fields that weren't recorded are left out,
+// and snapshots without a time or any fields are skipped.
+func (d *Day) WriteInfluxLine(w io.Writer, measurement string) error {
+	for _, snapshot := range d.Snapshots {
+		snapshotTime, ok := snapshot.EffectiveTime()
+		if !ok {
+			continue
+		}
+		var fields []string
+		if battery, ok := snapshot.BatteryFraction(); ok {
+			fields = append(fields, "battery="+strconv.FormatFloat(battery, 'f', -1, 64))
+		}
+		if snapshot.Steps != nil && *snapshot.Steps >= 0 {
+			fields = append(fields, "steps="+strconv.Itoa(*snapshot.Steps)+"i")
+		}
+		if average, ok := snapshot.AudioAverageDb(); ok {
+			fields = append(fields, "audio_avg="+strconv.FormatFloat(average, 'f', -1, 64))
+		}
+		if peak, ok := snapshot.AudioPeakDb(); ok {
+			fields = append(fields, "audio_peak="+strconv.FormatFloat(peak, 'f', -1, 64))
+		}
+		if temperature, ok := snapshot.TemperatureCelsius(); ok {
+			fields = append(fields, "temp_c="+strconv.FormatFloat(temperature, 'f', -1, 64))
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s %s %d\n", influxMeasurementEscaper.Replace(measurement), strings.Join(fields, ","), snapshotTime.UnixNano()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// csvHeader is the header row written by WriteCSV and WriteCSVStream
+var csvHeader = []string{"date", "id", "section", "impetus", "battery", "steps", "connection", "latitude", "longitude", "audio_avg", "audio_peak", "temp_c", "weather", "photos"}
+
+// csvRecord returns the CSV row for a snapshot, leaving fields that weren't recorded empty
+func csvRecord(snapshot Snapshot) []string {
+	formatFloat := func(value float64, ok bool) string {
+		if !ok {
+			return ""
+		}
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	record := make([]string, 0, len(csvHeader))
+	snapshotTime, ok := snapshot.EffectiveTime()
+	if ok {
+		record = append(record, snapshotTime.Format(time.RFC3339))
+	} else {
+		record = append(record, "")
+	}
+	record = append(record, snapshot.ID, snapshot.SectionIdentifier)
+	if snapshot.ReportImpetus != nil {
+		record = append(record, strconv.Itoa(snapshot.ReportImpetus.Impetus))
+	} else {
+		record = append(record, "")
+	}
+	if battery, ok := snapshot.BatteryFraction(); ok {
+		record = append(record, formatFloat(battery, true))
+	} else {
+		record = append(record, "")
+	}
+	if snapshot.Steps != nil {
+		record = append(record, strconv.Itoa(*snapshot.Steps))
+	} else {
+		record = append(record, "")
+	}
+	if snapshot.Connection != nil {
+		record = append(record, strconv.Itoa(snapshot.Connection.Type))
+	} else {
+		record = append(record, "")
+	}
+	lat, long, ok := snapshot.Coordinates()
+	record = append(record, formatFloat(lat, ok), formatFloat(long, ok))
+	record = append(record, formatFloat(snapshot.AudioAverageDb()), formatFloat(snapshot.AudioPeakDb()), formatFloat(snapshot.TemperatureCelsius()))
+	if snapshot.Weather != nil {
+		record = append(record, snapshot.Weather.WeatherDescription)
+	} else {
+		record = append(record, "")
+	}
+	photos := 0
+	if snapshot.PhotoSet != nil {
+		photos = len(snapshot.PhotoSet.Photos)
+	}
+	return append(record, strconv.Itoa(photos))
+}
+
+// writeCSVDay writes one CSV row per snapshot of the day
+func writeCSVDay(writer *csv.Writer, day Day) error {
+	for _, snapshot := range day.Snapshots {
+		if err := writer.Write(csvRecord(snapshot)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCSV writes a header row followed by one row per snapshot to w.
+// Battery is written as a BatteryFraction, and fields that weren't recorded are left empty.
+func (d *Day) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	if err := writeCSVDay(writer, *d); err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteCSVStream writes a single header row followed by the rows (see WriteCSV) of every day received on days until the channel is closed.
+// Days are not held in memory after they are written, and the output is flushed after each day so consumers can follow progress.
+// If an error is returned, the remaining days are not read from the channel.
+func WriteCSVStream(w io.Writer, days <-chan Day) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for day := range days {
+		if err := writeCSVDay(writer, day); err != nil {
+			return err
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// geoJSONFeatureCollection is a GeoJSON FeatureCollection
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+// geoJSONFeature is a GeoJSON Feature
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// geoJSONGeometry is a GeoJSON Geometry, with coordinates in longitude, latitude order
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// GeoJSONTrack returns a GeoJSON FeatureCollection with a single LineString feature connecting the located snapshots in time order.
+// The feature has a coordTimes property holding the RFC 3339 time of each point, which tools that support it use to animate the track.
+// Snapshots without coordinates or a time are skipped. If fewer than two points remain, the FeatureCollection has no features.
+func (d *Day) GeoJSONTrack() ([]byte, error) {
+	coordinates, pointTimes := d.trackPoints()
+	times := make([]string, len(pointTimes))
+	for i, pointTime := range pointTimes {
+		times[i] = pointTime.Format(time.RFC3339)
+	}
+	collection := geoJSONFeatureCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+	if len(coordinates) >= 2 {
+		collection.Features = append(collection.Features, geoJSONFeature{
+			Type:       "Feature",
+			Geometry:   geoJSONGeometry{Type: "LineString", Coordinates: coordinates},
+			Properties: map[string]interface{}{"coordTimes": times},
+		})
+	}
+	return json.Marshal(collection)
+}
@@ -1,23 +1,27 @@
 package reporter
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/user"
 	"path/filepath"
-	"strings"
 	"time"
 )
 
 // FilesystemBackend is a struct that stores the default report storage location
 type FilesystemBackend struct {
 	storageLocation string // The absolute path to the location of the Reporter JSON, usually ~/Dropbox/Apps/Reporter-App/
+	MaxReportBytes  int64  // The largest report that will be read. If 0, DefaultMaxReportBytes is used.
+	Logger          Logger // Receives diagnostic messages. If nil, nothing is logged.
 }
 
 // GetLatestReport searches the storageLocation to find the latest report file.
 // It searches based on filename, not on modified or created time, because
 // both can be updated after/before the date in the filename.
+// Empty files, such as those still being synced, are skipped.
 func (fs *FilesystemBackend) GetLatestReport() (File, error) {
 	var reporterFile File
 	files, err := ioutil.ReadDir(fs.storageLocation)
@@ -27,19 +31,23 @@ func (fs *FilesystemBackend) GetLatestReport() (File, error) {
 	var latestDate time.Time
 	var latestFile os.FileInfo
 	for _, file := range files {
-		if strings.Contains(file.Name(), "-reporter-export.json") {
+		if isReportFilename(file.Name()) && file.Size() > 0 {
 			filenameDate, err := dateForFilename(file.Name())
 			if err != nil {
-				return reporterFile, err
+				loggerOrNop(fs.Logger).Errorf("Skipping %s, unable to parse the date from its filename: %v", file.Name(), err)
+				continue
 			}
-			if filenameDate.After(latestDate) {
+			if latestFile == nil || filenameDate.After(latestDate) || (filenameDate.Equal(latestDate) && file.ModTime().After(latestFile.ModTime())) {
 				latestDate = filenameDate
 				latestFile = file
 			}
 		}
 	}
+	if latestFile == nil {
+		return reporterFile, errors.New("No reports found in filesystem")
+	}
 	filePath := filepath.Join(fs.storageLocation, latestFile.Name())
-	fileContents, err := ioutil.ReadFile(filePath)
+	fileContents, err := fs.readFile(filePath)
 	if err != nil {
 		return reporterFile, err
 	}
@@ -49,6 +57,7 @@ func (fs *FilesystemBackend) GetLatestReport() (File, error) {
 		Source:           "filesystem",
 		ModifiedTime:     latestFile.ModTime(),
 		TimeFromFilename: latestDate,
+		ConflictedCopy:   isConflictedCopy(latestFile.Name()),
 		Contents:         string(fileContents),
 	}, nil
 }
@@ -56,18 +65,22 @@ func (fs *FilesystemBackend) GetLatestReport() (File, error) {
 // GetReportForPath returns a File for the file at the full path specified.
 func (fs *FilesystemBackend) GetReportForPath(path string) (File, error) {
 	var reporterFile File
-	file, err := ioutil.ReadFile(path)
+	start := time.Now()
+	osOpen, err := os.Open(path)
 	if err != nil {
 		return reporterFile, err
 	}
-	osOpen, err := os.Open(path)
+	defer osOpen.Close()
+	fileStat, err := osOpen.Stat()
 	if err != nil {
 		return reporterFile, err
 	}
-	fileStat, err := osOpen.Stat()
+	file, err := readReport(osOpen, fs.MaxReportBytes)
 	if err != nil {
+		loggerOrNop(fs.Logger).Errorf("Unable to read %s: %v", path, err)
 		return reporterFile, err
 	}
+	loggerOrNop(fs.Logger).Debugf("Read %s (%d bytes) in %s", path, len(file), time.Since(start))
 	filenameDate, err := dateForFilename(path)
 	if err != nil {
 		return reporterFile, err
@@ -78,14 +91,22 @@ func (fs *FilesystemBackend) GetReportForPath(path string) (File, error) {
 		Source:           "filesystem",
 		ModifiedTime:     fileStat.ModTime(),
 		TimeFromFilename: filenameDate,
+		ConflictedCopy:   isConflictedCopy(path),
 		Contents:         string(file),
 	}, nil
 }
 
+// OpenReport opens the file at the full path specified for streaming. The caller must close it.
+func (fs *FilesystemBackend) OpenReport(ctx context.Context, path string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
 // GetReportForTime returns a File for the file with the date given in the filename
 func (fs *FilesystemBackend) GetReportForTime(date time.Time) (File, error) {
-	fileName := fmt.Sprintf("%s-reporter-export.json", date.Format("2006-01-02"))
-	filePath := filepath.Join(fs.storageLocation, fileName)
+	filePath := filepath.Join(fs.storageLocation, filenameForDate(date))
 	return fs.GetReportForPath(filePath)
 }
 
@@ -97,25 +118,52 @@ func (fs *FilesystemBackend) ListReports() ([]File, error) {
 		return allFiles, err
 	}
 	for _, file := range files {
-		if strings.Contains(file.Name(), "-reporter-export.json") {
-			filenameDate, err := dateForFilename(file.Name())
-			if err != nil {
-				return allFiles, err
-			}
-			filePath := filepath.Join(fs.storageLocation, file.Name())
-			singleFile := File{
-				Name:             file.Name(),
-				Path:             filePath,
-				Source:           "filesystem",
-				ModifiedTime:     file.ModTime(),
-				TimeFromFilename: filenameDate,
-			}
-			allFiles = append(allFiles, singleFile)
+		if !isReportFilename(file.Name()) {
+			loggerOrNop(fs.Logger).Debugf("Skipping %s, it is not a report", file.Name())
+			continue
 		}
+		filenameDate, err := dateForFilename(file.Name())
+		if err != nil {
+			loggerOrNop(fs.Logger).Errorf("Skipping %s, unable to parse the date from its filename: %v", file.Name(), err)
+			continue
+		}
+		filePath := filepath.Join(fs.storageLocation, file.Name())
+		singleFile := File{
+			Name:             file.Name(),
+			Path:             filePath,
+			Source:           "filesystem",
+			ModifiedTime:     file.ModTime(),
+			TimeFromFilename: filenameDate,
+			ConflictedCopy:   isConflictedCopy(file.Name()),
+		}
+		allFiles = append(allFiles, singleFile)
 	}
 	return allFiles, nil
 }
 
+// readFile reads the file at path, failing if it is larger than MaxReportBytes
+func (fs *FilesystemBackend) readFile(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readReport(file, fs.MaxReportBytes)
+}
+
+// SaveReport writes the contents of the File to the storageLocation using the File's name.
+// If the File has a ModifiedTime, it is applied to the written file.
+func (fs *FilesystemBackend) SaveReport(file File) error {
+	filePath := filepath.Join(fs.storageLocation, file.Name)
+	if err := ioutil.WriteFile(filePath, []byte(file.Contents), 0644); err != nil {
+		return err
+	}
+	if !file.ModifiedTime.IsZero() {
+		return os.Chtimes(filePath, file.ModifiedTime, file.ModifiedTime)
+	}
+	return nil
+}
+
 // NewFilesystemBackend returns a new local filesystem backend to read JSON from.
 // If a storageLocation isn't provided, the default location is
 //   ~/Dropbox/Apps/Reporter-App/
@@ -127,5 +175,5 @@ func NewFilesystemBackend(storageLocation string) (*FilesystemBackend, error) {
 		}
 		storageLocation = filepath.Join(usr.HomeDir, "Dropbox/Apps/Reporter-App/")
 	}
-	return &FilesystemBackend{storageLocation}, nil
+	return &FilesystemBackend{storageLocation: storageLocation}, nil
 }
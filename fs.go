@@ -0,0 +1,127 @@
+package reporter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// FSBackend is a struct that stores a file system and the directory within it containing reports.
+// Any fs.FS can be used, such as an embed.FS, os.DirFS or a zip.Reader.
+type FSBackend struct {
+	FS             fs.FS
+	Root           string // The directory within FS containing the Reporter JSON. Use "." for the top of FS.
+	MaxReportBytes int64  // The largest report that will be read. If 0, DefaultMaxReportBytes is used.
+	Logger         Logger // Receives diagnostic messages. If nil, nothing is logged.
+}
+
+// GetLatestReport searches the Root to find the latest report file.
+// It searches based on filename, not on modified or created time, because
+// both can be updated after/before the date in the filename.
+func (fsb *FSBackend) GetLatestReport() (File, error) {
+	var reporterFile File
+	files, err := fsb.ListReports()
+	if err != nil {
+		return reporterFile, err
+	}
+	latest, ok := latestReport(files)
+	if !ok {
+		return reporterFile, errors.New("No reports found in file system")
+	}
+	return fsb.GetReportForPath(latest.Path)
+}
+
+// GetReportForPath returns a File for the file at the path specified, relative to the top of FS.
+func (fsb *FSBackend) GetReportForPath(filePath string) (File, error) {
+	var reporterFile File
+	start := time.Now()
+	opened, err := fsb.FS.Open(filePath)
+	if err != nil {
+		return reporterFile, err
+	}
+	defer opened.Close()
+	fileStat, err := opened.Stat()
+	if err != nil {
+		return reporterFile, err
+	}
+	file, err := readReport(opened, fsb.MaxReportBytes)
+	if err != nil {
+		loggerOrNop(fsb.Logger).Errorf("Unable to read %s: %v", filePath, err)
+		return reporterFile, err
+	}
+	loggerOrNop(fsb.Logger).Debugf("Read %s (%d bytes) in %s", filePath, len(file), time.Since(start))
+	filenameDate, err := dateForFilename(filePath)
+	if err != nil {
+		return reporterFile, err
+	}
+	return File{
+		Name:             fileStat.Name(),
+		Path:             filePath,
+		Source:           "fs",
+		ModifiedTime:     fileStat.ModTime(),
+		TimeFromFilename: filenameDate,
+		ConflictedCopy:   isConflictedCopy(filePath),
+		Contents:         string(file),
+	}, nil
+}
+
+// OpenReport opens the file at the path specified, relative to the top of FS, for streaming. The caller must close it.
+func (fsb *FSBackend) OpenReport(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return fsb.FS.Open(filePath)
+}
+
+// GetReportForTime returns a File for the file with the date given in the filename
+func (fsb *FSBackend) GetReportForTime(date time.Time) (File, error) {
+	return fsb.GetReportForPath(path.Join(fsb.Root, filenameForDate(date)))
+}
+
+// ListReports lists all available reports
+func (fsb *FSBackend) ListReports() ([]File, error) {
+	var allFiles []File
+	entries, err := fs.ReadDir(fsb.FS, fsb.Root)
+	if err != nil {
+		return allFiles, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !isReportFilename(entry.Name()) {
+			loggerOrNop(fsb.Logger).Debugf("Skipping %s, it is not a report", entry.Name())
+			continue
+		}
+		filenameDate, err := dateForFilename(entry.Name())
+		if err != nil {
+			loggerOrNop(fsb.Logger).Errorf("Skipping %s, unable to parse the date from its filename: %v", entry.Name(), err)
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return allFiles, err
+		}
+		allFiles = append(allFiles, File{
+			Name:             entry.Name(),
+			Path:             path.Join(fsb.Root, entry.Name()),
+			Source:           "fs",
+			ModifiedTime:     info.ModTime(),
+			TimeFromFilename: filenameDate,
+			ConflictedCopy:   isConflictedCopy(entry.Name()),
+		})
+	}
+	return allFiles, nil
+}
+
+// NewFSBackend returns a new backend to read JSON from the root directory of any fs.FS.
+// If root isn't provided, the top of fsys is used.
+func NewFSBackend(fsys fs.FS, root string) (*FSBackend, error) {
+	if fsys == nil {
+		return nil, errors.New("No file system provided for FS backend")
+	}
+	if root == "" {
+		root = "."
+	}
+	return &FSBackend{FS: fsys, Root: root}, nil
+}
@@ -0,0 +1,160 @@
+package reporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// GCSBackend is a struct that stores the Google Cloud Storage bucket and the prefix reports are stored under
+type GCSBackend struct {
+	Bucket         *storage.BucketHandle
+	Prefix         string // The object name prefix of the Reporter JSON, i.e. Apps/Reporter-App/
+	MaxReportBytes int64  // The largest report that will be downloaded. If 0, DefaultMaxReportBytes is used.
+	Logger         Logger // Receives diagnostic messages. If nil, nothing is logged.
+}
+
+// GetLatestReport searches the objects under the Prefix to find the latest report file.
+// It searches based on filename, not on modified or created time, because
+// both can be updated after/before the date in the filename.
+func (gcs *GCSBackend) GetLatestReport() (File, error) {
+	var reporterFile File
+	files, err := gcs.ListReports()
+	if err != nil {
+		return reporterFile, err
+	}
+	newest, ok := latestReport(files)
+	if !ok {
+		return reporterFile, errors.New("No reports found in Google Cloud Storage")
+	}
+	return gcs.GetReportForPath(newest.Path)
+}
+
+// GetReportForPath returns a File for the object with the full name specified.
+func (gcs *GCSBackend) GetReportForPath(objectName string) (File, error) {
+	var reporterFile File
+	start := time.Now()
+	ctx := context.Background()
+	object := gcs.Bucket.Object(objectName)
+	attrs, err := object.Attrs(ctx)
+	if err != nil {
+		return reporterFile, gcsError(objectName, err)
+	}
+	reader, err := object.NewReader(ctx)
+	if err != nil {
+		return reporterFile, gcsError(objectName, err)
+	}
+	defer reader.Close()
+	file, err := readReport(reader, gcs.MaxReportBytes)
+	if err != nil {
+		loggerOrNop(gcs.Logger).Errorf("Unable to download %s: %v", objectName, err)
+		return reporterFile, err
+	}
+	loggerOrNop(gcs.Logger).Debugf("Downloaded %s (%d bytes) in %s", objectName, len(file), time.Since(start))
+	filenameDate, err := dateForFilename(objectName)
+	if err != nil {
+		return reporterFile, err
+	}
+	return File{
+		Name:             path.Base(objectName),
+		Path:             objectName,
+		Source:           "gcs",
+		ModifiedTime:     attrs.Updated,
+		TimeFromFilename: filenameDate,
+		ConflictedCopy:   isConflictedCopy(objectName),
+		Contents:         string(file),
+	}, nil
+}
+
+// OpenReport returns a reader for the object with the full name specified, which the caller must close.
+// The download is cancelled if ctx is done.
+func (gcs *GCSBackend) OpenReport(ctx context.Context, objectName string) (io.ReadCloser, error) {
+	reader, err := gcs.Bucket.Object(objectName).NewReader(ctx)
+	if err != nil {
+		return nil, gcsError(objectName, err)
+	}
+	return reader, nil
+}
+
+// gcsError wraps ErrReportNotFound with the object name if err says the object doesn't exist, and returns any other error unchanged
+func gcsError(objectName string, err error) error {
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("%s: %w", objectName, ErrReportNotFound)
+	}
+	return err
+}
+
+// GetReportForTime returns a File for the object with the date given in the filename
+func (gcs *GCSBackend) GetReportForTime(date time.Time) (File, error) {
+	return gcs.GetReportForPath(path.Join(gcs.Prefix, filenameForDate(date)))
+}
+
+// ListReports lists all available reports
+func (gcs *GCSBackend) ListReports() ([]File, error) {
+	objects := gcs.Bucket.Objects(context.Background(), &storage.Query{Prefix: gcs.Prefix})
+	return listReportObjects(objects.Next, gcs.Logger)
+}
+
+// listReportObjects returns a File for each report among the objects returned by next, which returns iterator.Done after the last one
+func listReportObjects(next func() (*storage.ObjectAttrs, error), logger Logger) ([]File, error) {
+	var allFiles []File
+	for {
+		attrs, err := next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return allFiles, err
+		}
+		if !isReportFilename(attrs.Name) {
+			loggerOrNop(logger).Debugf("Skipping %s, it is not a report", attrs.Name)
+			continue
+		}
+		filenameDate, err := dateForFilename(attrs.Name)
+		if err != nil {
+			loggerOrNop(logger).Errorf("Skipping %s, unable to parse the date from its filename: %v", attrs.Name, err)
+			continue
+		}
+		allFiles = append(allFiles, File{
+			Name:             path.Base(attrs.Name),
+			Path:             attrs.Name,
+			Source:           "gcs",
+			ModifiedTime:     attrs.Updated,
+			TimeFromFilename: filenameDate,
+			ConflictedCopy:   isConflictedCopy(attrs.Name),
+		})
+	}
+	return allFiles, nil
+}
+
+// ListReportsBetween lists the reports whose filename date falls between the days of start and end, inclusive.
+// Object names start with the date, so only the objects in that range are listed by Google Cloud Storage.
+func (gcs *GCSBackend) ListReportsBetween(start, end time.Time) ([]File, error) {
+	objects := gcs.Bucket.Objects(context.Background(), reportRangeQuery(gcs.Prefix, start, end))
+	return listReportObjects(objects.Next, gcs.Logger)
+}
+
+// reportRangeQuery returns a query for the objects under prefix whose names start with a date between the days of start and end, inclusive
+func reportRangeQuery(prefix string, start, end time.Time) *storage.Query {
+	return &storage.Query{
+		Prefix:      prefix,
+		StartOffset: path.Join(prefix, filenameForDate(start)),
+		EndOffset:   path.Join(prefix, filenameForDate(end.AddDate(0, 0, 1))),
+	}
+}
+
+// NewGCSBackend returns a new Google Cloud Storage backend to read JSON from.
+// You must provide a bucket handle, which you can get from a storage.Client.
+// The prefix is the object name prefix reports are stored under and may be empty.
+func NewGCSBackend(bucket *storage.BucketHandle, prefix string) (*GCSBackend, error) {
+	if bucket == nil {
+		return nil, errors.New("No bucket provided for Google Cloud Storage backend")
+	}
+	return &GCSBackend{Bucket: bucket, Prefix: prefix}, nil
+}
@@ -1,18 +1,32 @@
 // Package reporter provides a Golang interface for parsing JSON generated by Reporter (http://reporter-app.com).
-// It has built in support for getting the JSON from the local filesystem, a string, or even Dropbox.
+// It has built in support for getting the JSON from the local filesystem, a string, or even Dropbox and Google Cloud Storage.
 // Most of the work is based on experimentation and the schema guide found at https://gist.github.com/dbreunig/9315705.
 //
 // Many comments in the code come directly from the schema gist
 package reporter
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"iter"
+	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
-// SchemaVersion stores the schema version for the day that is currently being processed.
+// SchemaVersion stores the schema version of the last decoded day, which json.Marshal uses for timestamps and tokens.
 var SchemaVersion = 2 // Schema version 1 used Apple epoch timestamps and no ID's for objects.
 
+// decodeMutex serializes marshaling for a specific schema version and recording the version of a decoded day, since both change SchemaVersion.
+var decodeMutex sync.Mutex
+
 // File contains information about the JSON source file
 type File struct {
 	Name             string    `json:"name,omitempty"`
@@ -20,6 +34,7 @@ type File struct {
 	Source           string    `json:"source,omitempty"`
 	ModifiedTime     time.Time `json:"modifiedTime,omitempty"`
 	TimeFromFilename time.Time `json:"timeFromFilename,omitempty"`
+	ConflictedCopy   bool      `json:"conflictedCopy,omitempty"` // Set when the file is a Dropbox conflicted copy of the report for TimeFromFilename
 	Contents         string    `json:"contents,omitempty"`
 }
 
@@ -34,27 +49,394 @@ type Backend interface {
 	ListReports() ([]File, error)
 }
 
+// ErrReportNotFound is returned when a backend has no report for the requested date
+var ErrReportNotFound = errors.New("Report not found")
+
+// A RangeLister is a Backend that can list only the reports within a date range.
+// Backends that are able to filter server side, such as GCSBackend, implement it so ListReportsBetween avoids listing everything.
+type RangeLister interface {
+	ListReportsBetween(start, end time.Time) ([]File, error)
+}
+
+// A ReportSaver is a Backend that reports can be written back to.
+type ReportSaver interface {
+	SaveReport(File) error
+}
+
+// A ReportOpener is a Backend that can stream the contents of a report instead of reading all of it into File.Contents.
+type ReportOpener interface {
+	OpenReport(ctx context.Context, path string) (io.ReadCloser, error)
+}
+
+// OpenReport returns a reader for the contents of the report at path, which the caller must close.
+// If the backend is a ReportOpener the contents are streamed, otherwise the report is read with GetReportForPath.
+// Streamed reports are not limited by the backend's MaxReportBytes.
+func OpenReport(ctx context.Context, b Backend, path string) (io.ReadCloser, error) {
+	if opener, ok := b.(ReportOpener); ok {
+		return opener.OpenReport(ctx, path)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	file, err := b.GetReportForPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(strings.NewReader(file.Contents)), nil
+}
+
+// A Logger receives diagnostic messages from a backend, such as the files skipped while listing reports
+// and the size and duration of every download. Set the Logger field of a backend to receive them.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// nopLogger is a Logger that discards every message
+type nopLogger struct{}
+
+func (nopLogger) Debugf(format string, args ...interface{}) {}
+func (nopLogger) Errorf(format string, args ...interface{}) {}
+
+// loggerOrNop returns l, or a Logger that discards every message if l is nil
+func loggerOrNop(l Logger) Logger {
+	if l == nil {
+		return nopLogger{}
+	}
+	return l
+}
+
+// ListReportsBetween returns the reports whose filename date falls between the days of start and end, inclusive.
+// If the backend is a RangeLister, it is asked to do the filtering, otherwise all reports are listed and then filtered.
+func ListReportsBetween(b Backend, start, end time.Time) ([]File, error) {
+	if lister, ok := b.(RangeLister); ok {
+		return lister.ListReportsBetween(start, end)
+	}
+	files, err := b.ListReports()
+	if err != nil {
+		return nil, err
+	}
+	startDay, endDay := truncateToDay(start), truncateToDay(end)
+	var filtered []File
+	for _, file := range files {
+		if file.TimeFromFilename.Before(startDay) || file.TimeFromFilename.After(endDay) {
+			continue
+		}
+		filtered = append(filtered, file)
+	}
+	return filtered, nil
+}
+
+// Sync copies every report in src that is missing from dst, or that was modified in src after the copy in dst, and returns the names of the copied reports.
+// dst must implement ReportSaver. Reports are matched by file name.
+func Sync(ctx context.Context, src, dst Backend) ([]string, error) {
+	var copied []string
+	saver, ok := dst.(ReportSaver)
+	if !ok {
+		return copied, errors.New("Destination backend does not support saving reports")
+	}
+	srcFiles, err := src.ListReports()
+	if err != nil {
+		return copied, err
+	}
+	dstFiles, err := dst.ListReports()
+	if err != nil {
+		return copied, err
+	}
+	existing := make(map[string]time.Time)
+	for _, file := range dstFiles {
+		existing[file.Name] = file.ModifiedTime
+	}
+	for _, file := range srcFiles {
+		if modified, ok := existing[file.Name]; ok && !file.ModifiedTime.After(modified) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return copied, err
+		}
+		report, err := src.GetReportForPath(file.Path)
+		if err != nil {
+			return copied, err
+		}
+		if err := saver.SaveReport(report); err != nil {
+			return copied, err
+		}
+		copied = append(copied, file.Name)
+	}
+	return copied, nil
+}
+
+// CompareCatalogs lists the reports in a and b and returns the report dates that only exist in a and only exist in b, in ascending order.
+// Reports are matched by the date in their filename, so no report is downloaded. Conflicted copies count as the report for their date.
+func CompareCatalogs(a, b Backend) (onlyA, onlyB []time.Time, err error) {
+	aDates, err := catalogDates(a)
+	if err != nil {
+		return nil, nil, err
+	}
+	bDates, err := catalogDates(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	for date := range aDates {
+		if !bDates[date] {
+			onlyA = append(onlyA, date)
+		}
+	}
+	for date := range bDates {
+		if !aDates[date] {
+			onlyB = append(onlyB, date)
+		}
+	}
+	sortTimes(onlyA)
+	sortTimes(onlyB)
+	return onlyA, onlyB, nil
+}
+
+// catalogDates returns the set of filename dates of the reports in b
+func catalogDates(b Backend) (map[time.Time]bool, error) {
+	files, err := b.ListReports()
+	if err != nil {
+		return nil, err
+	}
+	dates := make(map[time.Time]bool, len(files))
+	for _, file := range files {
+		dates[truncateToDay(file.TimeFromFilename)] = true
+	}
+	return dates, nil
+}
+
+// GetReportsForDates fetches the report for each of the dates using up to concurrency goroutines at once, without listing every report.
+// The returned files and errors are parallel to dates: a date whose report couldn't be fetched has an empty File and an error,
+// which matches ErrReportNotFound (using errors.Is) if the backend has no report for that date. One failure does not stop the others.
+func GetReportsForDates(ctx context.Context, b Backend, dates []time.Time, concurrency int) ([]File, []error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	files := make([]File, len(dates))
+	errs := make([]error, len(dates))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				if err := ctx.Err(); err != nil {
+					errs[index] = err
+					continue
+				}
+				file, err := b.GetReportForTime(dates[index])
+				if errors.Is(err, fs.ErrNotExist) {
+					err = ErrReportNotFound
+				}
+				if err != nil {
+					errs[index] = fmt.Errorf("%s: %w", filenameForDate(dates[index]), err)
+					continue
+				}
+				files[index] = file
+			}
+		}()
+	}
+	for index := range dates {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	return files, errs
+}
+
+// AllSnapshots downloads and decodes every report in the backend, returning all of their snapshots in filename date order.
+// Up to concurrency goroutines download and decode reports at once.
+// A report that fails to download or decode does not stop the others; its error is returned alongside the snapshots that could be loaded.
+// Once ctx is done, the reports that haven't been fetched yet each return ctx's error.
+func AllSnapshots(ctx context.Context, b Backend, concurrency int) ([]Snapshot, []error) {
+	files, err := b.ListReports()
+	if err != nil {
+		return nil, []error{err}
+	}
+	sortFiles(files)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	days := make([][]Snapshot, len(files))
+	errs := make([]error, len(files))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				if err := ctx.Err(); err != nil {
+					errs[index] = err
+					continue
+				}
+				file, err := b.GetReportForPath(files[index].Path)
+				if err != nil {
+					errs[index] = fmt.Errorf("%s: %w", files[index].Name, err)
+					continue
+				}
+				day, err := DecodeFile(file)
+				if err != nil {
+					errs[index] = fmt.Errorf("%s: %w", files[index].Name, err)
+					continue
+				}
+				days[index] = day.Snapshots
+			}
+		}()
+	}
+	for index := range files {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	var snapshots []Snapshot
+	var allErrors []error
+	for index := range files {
+		snapshots = append(snapshots, days[index]...)
+		if errs[index] != nil {
+			allErrors = append(allErrors, errs[index])
+		}
+	}
+	return snapshots, allErrors
+}
+
+// SnapshotsSeq returns an iterator over the snapshots of every report in the backend in filename date order.
+// Reports are only downloaded and decoded as the iteration reaches them, so stopping early avoids loading the rest.
+// A report that fails to download or decode yields its error with an empty Snapshot and iteration continues with the next report.
+func SnapshotsSeq(b Backend) iter.Seq2[Snapshot, error] {
+	return func(yield func(Snapshot, error) bool) {
+		files, err := b.ListReports()
+		if err != nil {
+			yield(Snapshot{}, err)
+			return
+		}
+		sortFiles(files)
+		for _, file := range files {
+			report, err := b.GetReportForPath(file.Path)
+			if err == nil {
+				var day Day
+				day, err = DecodeFile(report)
+				if err == nil {
+					for snapshot := range day.AllSnapshotsSeq() {
+						if !yield(snapshot, nil) {
+							return
+						}
+					}
+					continue
+				}
+			}
+			if !yield(Snapshot{}, fmt.Errorf("%s: %w", file.Name, err)) {
+				return
+			}
+		}
+	}
+}
+
+// LatestDay returns the decoded Day for the latest report in the backend
+func LatestDay(b Backend) (Day, error) {
+	file, err := b.GetLatestReport()
+	if err != nil {
+		return Day{}, fmt.Errorf("Unable to get latest report: %w", err)
+	}
+	day, err := DecodeFile(file)
+	if err != nil {
+		return day, fmt.Errorf("Unable to decode %s: %w", file.Name, err)
+	}
+	return day, nil
+}
+
 // DecodeJSONString returns a Day for a raw JSON string
 func DecodeJSONString(jsonString string) (Day, error) {
-	var day Day
-	err := json.Unmarshal([]byte(jsonString), &day)
+	return defaultDecoder.Decode([]byte(jsonString))
+}
+
+// DecodeReader reads all of r and returns the Day it contains
+func DecodeReader(r io.Reader) (Day, error) {
+	return decodeReader(r, "")
+}
+
+// DecodeStdin reads all of standard input and returns the Day it contains, for use in shell pipelines.
+// The Day's FileInfo has a Source of "stdin".
+func DecodeStdin() (Day, error) {
+	return decodeReader(os.Stdin, "stdin")
+}
+
+// decodeReader reads all of r and decodes it, recording source on the Day's FileInfo
+func decodeReader(r io.Reader, source string) (Day, error) {
+	contents, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Day{}, fmt.Errorf("Unable to read report: %w", err)
+	}
+	day, err := defaultDecoder.DecodeFile(File{Source: source, Contents: string(contents)})
 	if err != nil {
-		return day, err
+		return day, fmt.Errorf("Unable to decode report: %w", err)
 	}
-	day.SchemaVersion = SchemaVersion
 	return day, nil
 }
 
+// RoundTripEqual decodes jsonBytes into a Day, encodes it again and compares the two JSON documents.
+// It returns true if they are equivalent. If they aren't, the returned error describes every difference, one per line.
+// An error is also returned if jsonBytes can't be decoded.
+func RoundTripEqual(jsonBytes []byte) (bool, error) {
+	day, err := defaultDecoder.Decode(jsonBytes)
+	if err != nil {
+		return false, err
+	}
+	encoded, err := day.JSON()
+	if err != nil {
+		return false, err
+	}
+	var original, roundTripped interface{}
+	if err := json.Unmarshal(jsonBytes, &original); err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(encoded, &roundTripped); err != nil {
+		return false, err
+	}
+	if diffs := diffJSONValues("", "", original, roundTripped); len(diffs) > 0 {
+		return false, fmt.Errorf("Round tripped JSON does not match:\n%s", strings.Join(diffs, "\n"))
+	}
+	return true, nil
+}
+
+// DecodeDays returns the Days in raw JSON that is either a single day object or an array of day objects,
+// as produced by exporters that combine several days into one file. The schema version is detected separately for each day.
+func DecodeDays(b []byte) ([]Day, error) {
+	trimmed := bytes.Trim(bytes.TrimPrefix(b, utf8BOM), " \t\r\n\x00")
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		day, err := defaultDecoder.Decode(b)
+		if err != nil {
+			return nil, err
+		}
+		return []Day{day}, nil
+	}
+	var rawDays []json.RawMessage
+	if err := json.Unmarshal(trimmed, &rawDays); err != nil {
+		return nil, err
+	}
+	days := make([]Day, 0, len(rawDays))
+	for i, rawDay := range rawDays {
+		day, err := defaultDecoder.Decode(rawDay)
+		if err != nil {
+			return days, fmt.Errorf("Unable to decode day %d: %w", i, err)
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
 // DecodeFile will return a Day for a given File
 func DecodeFile(file File) (Day, error) {
-	var day Day
-	err := json.Unmarshal([]byte(file.Contents), &day)
+	return defaultDecoder.DecodeFile(file)
+}
+
+// DecodeFileDetailed returns the Day for a given File along with the schema version that was detected while decoding it.
+// The version is also stored on Day.SchemaVersion.
+func DecodeFileDetailed(file File) (Day, int, error) {
+	day, err := DecodeFile(file)
 	if err != nil {
-		return day, err
+		return day, 0, err
 	}
-	file.Contents = ""
-	day.FileInfo = file
-	day.Date = file.TimeFromFilename
-	day.SchemaVersion = SchemaVersion
-	return day, nil
+	return day, day.SchemaVersion, nil
 }
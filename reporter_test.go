@@ -1,10 +1,29 @@
 package reporter
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/stacktic/dropbox"
+	"google.golang.org/api/iterator"
 )
 
 func thingToMap(t *testing.T, thing []byte) map[string]interface{} {
@@ -24,7 +43,8 @@ func compareOutput(t *testing.T, filepath string) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	parsedJSON, err := json.Marshal(loadTestFile(t, filepath))
+	day := loadTestFile(t, filepath)
+	parsedJSON, err := json.Marshal(day)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -35,7 +55,11 @@ func compareOutput(t *testing.T, filepath string) {
 	if reflect.DeepEqual(parsedJSONMap, fileJSONMap) {
 		t.Log("Test file JSON matches output JSON of go.reporter")
 	} else {
-		t.Fatal("Test file JSON does NOT match output JSON of go.reporter")
+		reparsed, err := DecodeJSONString(string(parsedJSON))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Fatalf("Test file JSON does NOT match output JSON of go.reporter:\n%s", strings.Join(day.Diff(reparsed), "\n"))
 	}
 }
 
@@ -63,6 +87,52 @@ func TestDecodeFileVersionTwo(t *testing.T) {
 	compareOutput(t, "./testData/2015-10-23-reporter-export.json")
 }
 
+func TestDayAudioStats(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	minDb, maxDb, avgDb, ok := day.AudioStats()
+	if !ok {
+		t.Fatal("Expected audio stats for a day with audio")
+	}
+	positive := func(average float64) float64 { return (average + 65) * 2 }
+	expected := []float64{positive(-58.84077), positive(-36.42973), (positive(-47.93216) + positive(-51.21036) + positive(-36.42973) + positive(-58.84077)) / 4}
+	for i, value := range []float64{minDb, maxDb, avgDb} {
+		if math.Abs(value-expected[i]) > 1e-9 {
+			t.Errorf("Audio stats do not match expected value! We were expecting %v but got %v", expected, []float64{minDb, maxDb, avgDb})
+			break
+		}
+	}
+	withoutAudio := Day{Snapshots: []Snapshot{{}, {Audio: &Audio{}}}}
+	if minDb, maxDb, avgDb, ok := withoutAudio.AudioStats(); ok || minDb != 0 || maxDb != 0 || avgDb != 0 {
+		t.Errorf("Expected no audio stats for a day without audio but got %v, %v, %v", minDb, maxDb, avgDb)
+	}
+}
+
+func TestDecodeFileDetailed(t *testing.T) {
+	backend, err := NewFilesystemBackend("./testData")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]int{"2014-01-15-reporter-export.json": 1, "2015-10-23-reporter-export.json": 2} {
+		file, err := backend.GetReportForPath(filepath.Join("./testData", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		day, version, err := DecodeFileDetailed(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != expected || day.SchemaVersion != expected {
+			t.Errorf("Schema version of %s does not match expected value! We were expecting %d but got %d (Day.SchemaVersion %d)", name, expected, version, day.SchemaVersion)
+		}
+		if day.FileInfo.Name != name || len(day.Snapshots) == 0 {
+			t.Errorf("Expected %s to be decoded with its snapshots and file info but got %s", name, day)
+		}
+	}
+	if _, version, err := DecodeFileDetailed(File{Name: "2015-10-24-reporter-export.json", Contents: "{not json"}); err == nil || version != 0 {
+		t.Errorf("Expected an error and no schema version for invalid JSON but got %d (%v)", version, err)
+	}
+}
+
 func TestAudioPositiveAverageDb(t *testing.T) {
 	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
 	latestSnapshot := day.GetLatestSnapshot()
@@ -87,4 +157,2760 @@ func TestAudioPositivePeakDb(t *testing.T) {
 	if unrounded != 30.4512 {
 		t.Errorf("Positive Db peak does not match expected value! We were expecting 30.45 but got %f", unrounded)
 	}
+	if whole := latestSnapshot.Audio.PositivePeakDbPlaces(0); whole != 30 {
+		t.Errorf("Positive Db peak does not match expected value! We were expecting 30 but got %f", whole)
+	}
+	if oneDecimal := latestSnapshot.Audio.PositiveAverageDbPlaces(1); oneDecimal != 12.3 {
+		t.Errorf("Positive Db average does not match expected value! We were expecting 12.3 but got %f", oneDecimal)
+	}
+}
+
+func TestDayWeatherSummary(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	min, max, dominant, ok := day.WeatherSummary()
+	if !ok {
+		t.Fatal("Expected a weather summary but got none")
+	}
+	if min != 16.6 || max != 23.2 {
+		t.Errorf("Temperature range does not match expected value! We were expecting 16.6-23.2 but got %f-%f", min, max)
+	}
+	if dominant != "Mostly Cloudy" {
+		t.Errorf("Dominant weather does not match expected value! We were expecting Mostly Cloudy but got %s", dominant)
+	}
+	if _, _, _, ok := (&Day{}).WeatherSummary(); ok {
+		t.Error("Expected no weather summary for a day without snapshots")
+	}
+	withoutTemperatures := Day{Snapshots: []Snapshot{
+		{Weather: &Weather{WeatherDescription: "Fog"}},
+		{Weather: &Weather{WeatherDescription: "Fog"}},
+		{Weather: &Weather{WeatherDescription: "Clear"}},
+	}}
+	min, max, dominant, ok = withoutTemperatures.WeatherSummary()
+	if ok || min != 0 || max != 0 {
+		t.Errorf("Expected no temperature range without temperatures but got %f-%f", min, max)
+	}
+	if dominant != "Fog" {
+		t.Errorf("Dominant weather does not match expected value! We were expecting Fog but got %s", dominant)
+	}
+}
+
+func TestDayToFile(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	file, err := day.ToFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Name != "2015-10-23-reporter-export.json" {
+		t.Errorf("File name does not match expected value! We were expecting 2015-10-23-reporter-export.json but got %s", file.Name)
+	}
+	if !file.TimeFromFilename.Equal(day.Date) {
+		t.Errorf("File time does not match expected value! We were expecting %s but got %s", day.Date, file.TimeFromFilename)
+	}
+	decoded, err := DecodeFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Snapshots) != len(day.Snapshots) {
+		t.Errorf("Snapshot count does not match expected value! We were expecting %d but got %d", len(day.Snapshots), len(decoded.Snapshots))
+	}
+}
+
+func TestExtraFieldsRoundTrip(t *testing.T) {
+	input := `{"snapshots":[{"battery":0.9,"newSnapshotField":{"a":1}}],"newDayField":[1,2]}`
+	day, err := DecodeJSONString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := day.Extra()["newDayField"]; !ok {
+		t.Error("Expected newDayField to be captured on the day")
+	}
+	if _, ok := day.Snapshots[0].Extra()["newSnapshotField"]; !ok {
+		t.Error("Expected newSnapshotField to be captured on the snapshot")
+	}
+	output, err := json.Marshal(day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(thingToMap(t, output), thingToMap(t, []byte(input))) {
+		t.Errorf("Extra fields were not written back out! We were expecting %s but got %s", input, output)
+	}
+}
+
+func TestDayPhotoCount(t *testing.T) {
+	day := loadTestFile(t, "./testData/2014-01-15-reporter-export.json")
+	if count := day.PhotoCount(); count != 5 {
+		t.Errorf("Photo count does not match expected value! We were expecting 5 but got %d", count)
+	}
+	if photos := day.AllPhotos(); len(photos) != 5 {
+		t.Errorf("Photo list length does not match expected value! We were expecting 5 but got %d", len(photos))
+	}
+}
+
+func TestListReportsBetween(t *testing.T) {
+	backend, err := NewFilesystemBackend("./testData")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2015, time.October, 23, 12, 0, 0, 0, time.UTC)
+	files, err := ListReportsBetween(backend, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name != "2015-10-23-reporter-export.json" {
+		t.Errorf("Expected only the 2015-10-23 report but got %v", files)
+	}
+}
+
+func TestGCSReportRangeQuery(t *testing.T) {
+	start := time.Date(2015, time.October, 22, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2015, time.October, 23, 18, 0, 0, 0, time.UTC)
+	query := reportRangeQuery("Apps/Reporter-App/", start, end)
+	if query.Prefix != "Apps/Reporter-App/" || query.StartOffset != "Apps/Reporter-App/2015-10-22-reporter-export.json" || query.EndOffset != "Apps/Reporter-App/2015-10-24-reporter-export.json" {
+		t.Errorf("Query does not match expected value! We got %+v", query)
+	}
+	// Google Cloud Storage lists the names from StartOffset inclusive to EndOffset exclusive
+	var objects []*storage.ObjectAttrs
+	for _, name := range []string{
+		"2015-10-21-reporter-export.json",
+		"2015-10-22-reporter-export.json",
+		"2015-10-23-reporter-export (conflicted copy).json",
+		"2015-10-23-reporter-export.json",
+		"2015-10-24-reporter-export.json",
+	} {
+		name = "Apps/Reporter-App/" + name
+		if name >= query.StartOffset && name < query.EndOffset {
+			objects = append(objects, &storage.ObjectAttrs{Name: name})
+		}
+	}
+	next := func() (*storage.ObjectAttrs, error) {
+		if len(objects) == 0 {
+			return nil, iterator.Done
+		}
+		attrs := objects[0]
+		objects = objects[1:]
+		return attrs, nil
+	}
+	files, err := listReportObjects(next, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	expected := []string{"2015-10-22-reporter-export.json", "2015-10-23-reporter-export (conflicted copy).json", "2015-10-23-reporter-export.json"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Reports in range do not match expected value! We were expecting %v but got %v", expected, names)
+	}
+	if query := reportRangeQuery("", start, end); query.StartOffset != "2015-10-22-reporter-export.json" {
+		t.Errorf("Expected no prefix on the offsets without one but got %+v", query)
+	}
+}
+
+func TestSync(t *testing.T) {
+	src, err := NewFilesystemBackend("./testData")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := NewFilesystemBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, err := Sync(context.Background(), src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copied) != 2 {
+		t.Errorf("Expected 2 reports to be copied but got %v", copied)
+	}
+	copied, err = Sync(context.Background(), src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copied) != 0 {
+		t.Errorf("Expected no reports to be copied on the second sync but got %v", copied)
+	}
+}
+
+func TestDayMarshalJSONOptionsOmitDebugFields(t *testing.T) {
+	day := loadTestFile(t, "./testData/2014-01-15-reporter-export.json")
+	output, err := day.MarshalJSONOptions(MarshalOptions{OmitDebugFields: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := thingToMap(t, output)["snapshots"].([]interface{})[0].(map[string]interface{})
+	for _, field := range []string{"background", "dwellStatus", "sync"} {
+		if _, ok := snapshot[field]; ok {
+			t.Errorf("Expected %s to be omitted from the output", field)
+		}
+	}
+	if day.Snapshots[0].Sync == nil {
+		t.Error("Expected the original day to be left untouched")
+	}
+}
+
+func TestDropboxReportPath(t *testing.T) {
+	for _, storageLocation := range []string{"/Apps/Reporter-App", "/Apps/Reporter-App/"} {
+		backend := &DropboxBackend{StorageLocation: storageLocation}
+		reportPath, err := backend.reportPath("2015-10-23-reporter-export.json")
+		if err != nil || reportPath != "/Apps/Reporter-App/2015-10-23-reporter-export.json" {
+			t.Errorf("Report path for storage location %s does not match expected value! We got %s", storageLocation, reportPath)
+		}
+	}
+}
+
+func TestDropboxListReportsDelta(t *testing.T) {
+	entry := func(path string) dropbox.DeltaEntry {
+		return dropbox.DeltaEntry{Path: strings.ToLower(path), Entry: &dropbox.Entry{Path: path}}
+	}
+	deleted := func(path string) dropbox.DeltaEntry {
+		return dropbox.DeltaEntry{Path: strings.ToLower(path)}
+	}
+	pages := map[string]*dropbox.DeltaPage{
+		"": {Reset: true, HasMore: true, Cursor: dropbox.Cursor{Cursor: "c1"}, Entries: []dropbox.DeltaEntry{
+			entry("/Apps/Reporter-App/2015-10-23-reporter-export.json"),
+			{Path: "/apps/reporter-app/photos", Entry: &dropbox.Entry{Path: "/Apps/Reporter-App/photos", IsDir: true}},
+			entry("/Apps/Reporter-App/notes.txt"),
+			entry("/Apps/Reporter-App/2015-13-45-reporter-export.json"),
+			entry("/Apps/Reporter-App/2015-10-24-reporter-export.json"),
+		}},
+		"c1": {Cursor: dropbox.Cursor{Cursor: "c2"}, Entries: []dropbox.DeltaEntry{
+			deleted("/Apps/Reporter-App/2015-10-24-reporter-export.json"),
+			entry("/Apps/Reporter-App/2015-10-23-reporter-export.json"),
+			deleted("/Apps/Reporter-App/2015-10-22-reporter-export.json"),
+		}},
+		"stale": {HasMore: true, Cursor: dropbox.Cursor{Cursor: "c3"}, Entries: []dropbox.DeltaEntry{
+			entry("/Apps/Reporter-App/2015-10-24-reporter-export.json"),
+		}},
+		"c3": {Reset: true, Cursor: dropbox.Cursor{Cursor: "c4"}, Entries: []dropbox.DeltaEntry{
+			entry("/Apps/Reporter-App/2015-10-23-reporter-export.json"),
+		}},
+		"failing": {HasMore: true, Cursor: dropbox.Cursor{Cursor: "c5"}, Entries: []dropbox.DeltaEntry{
+			entry("/Apps/Reporter-App/2015-10-23-reporter-export.json"),
+		}},
+	}
+	delta := func(cursor, pathPrefix string) (*dropbox.DeltaPage, error) {
+		if pathPrefix != "/Apps/Reporter-App" {
+			t.Errorf("Path prefix does not match expected value! We were expecting /Apps/Reporter-App but got %s", pathPrefix)
+		}
+		page, ok := pages[cursor]
+		if !ok {
+			return nil, fmt.Errorf("Unknown cursor %s", cursor)
+		}
+		return page, nil
+	}
+	for _, test := range []struct {
+		cursor         string
+		expectedCursor string
+		failing        bool
+	}{
+		{"", "c2", false},
+		{"stale", "c4", false},
+		{"failing", "c5", true},
+	} {
+		logger := &recordingLogger{}
+		files, cursor, err := listReportsDelta(delta, "/Apps/Reporter-App", test.cursor, logger)
+		if (err != nil) != test.failing {
+			t.Errorf("Unexpected error for cursor %q: %v", test.cursor, err)
+		}
+		if cursor != test.expectedCursor {
+			t.Errorf("Cursor does not match expected value! We were expecting %q but got %q", test.expectedCursor, cursor)
+		}
+		if len(files) != 1 || files[0].Name != "2015-10-23-reporter-export.json" || files[0].Source != "dropbox" {
+			t.Errorf("Changed files for cursor %q do not match expected value! We were expecting only 2015-10-23-reporter-export.json but got %+v", test.cursor, files)
+		}
+		if expected := map[string]int{"": 1}[test.cursor]; len(logger.errors) != expected {
+			t.Errorf("Expected %d logged errors for cursor %q but got %v", expected, test.cursor, logger.errors)
+		}
+	}
+}
+
+func TestGCSListReportObjects(t *testing.T) {
+	updated := time.Date(2015, time.October, 24, 1, 2, 3, 0, time.UTC)
+	objects := []*storage.ObjectAttrs{
+		{Name: "Apps/Reporter-App/2015-10-23-reporter-export.json", Updated: updated},
+		{Name: "Apps/Reporter-App/notes.txt"},
+		{Name: "Apps/Reporter-App/2015-13-45-reporter-export.json"},
+		{Name: "Apps/Reporter-App/2015-10-24-reporter-export (conflicted copy).json"},
+	}
+	next := func() (*storage.ObjectAttrs, error) {
+		if len(objects) == 0 {
+			return nil, iterator.Done
+		}
+		attrs := objects[0]
+		objects = objects[1:]
+		return attrs, nil
+	}
+	logger := &recordingLogger{}
+	files, err := listReportObjects(next, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("File count does not match expected value! We were expecting 2 but got %d", len(files))
+	}
+	expected := File{
+		Name:             "2015-10-23-reporter-export.json",
+		Path:             "Apps/Reporter-App/2015-10-23-reporter-export.json",
+		Source:           "gcs",
+		ModifiedTime:     updated,
+		TimeFromFilename: time.Date(2015, time.October, 23, 0, 0, 0, 0, time.UTC),
+	}
+	if !reflect.DeepEqual(files[0], expected) {
+		t.Errorf("File does not match expected value! We were expecting %+v but got %+v", expected, files[0])
+	}
+	if !files[1].ConflictedCopy {
+		t.Error("Expected the conflicted copy to be marked as one")
+	}
+	if len(logger.debug) != 1 || len(logger.errors) != 1 {
+		t.Errorf("Expected the other objects to be skipped and logged but got %v and %v", logger.debug, logger.errors)
+	}
+	failing := errors.New("Listing failed")
+	if _, err := listReportObjects(func() (*storage.ObjectAttrs, error) { return nil, failing }, nil); err != failing {
+		t.Errorf("Expected the listing error to be returned but got %v", err)
+	}
+}
+
+func TestGCSError(t *testing.T) {
+	err := gcsError("Apps/Reporter-App/2015-10-23-reporter-export.json", fmt.Errorf("Reading: %w", storage.ErrObjectNotExist))
+	if !errors.Is(err, ErrReportNotFound) || !strings.HasPrefix(err.Error(), "Apps/Reporter-App/2015-10-23-reporter-export.json: ") {
+		t.Errorf("Expected a missing object to be reported as ErrReportNotFound but got %v", err)
+	}
+	other := errors.New("Permission denied")
+	if err := gcsError("Apps/Reporter-App/2015-10-23-reporter-export.json", other); err != other {
+		t.Errorf("Expected other errors to be returned unchanged but got %v", err)
+	}
+}
+
+func TestDropboxError(t *testing.T) {
+	err := dropboxError("/Apps/Reporter-App/2015-10-23-reporter-export.json", &dropbox.Error{StatusCode: http.StatusNotFound, Text: "File not found"})
+	if !errors.Is(err, ErrReportNotFound) || !strings.HasPrefix(err.Error(), "/Apps/Reporter-App/2015-10-23-reporter-export.json: ") {
+		t.Errorf("Expected a missing file to be reported as ErrReportNotFound but got %v", err)
+	}
+	other := &dropbox.Error{StatusCode: http.StatusUnauthorized, Text: "Invalid access token"}
+	if err := dropboxError("/Apps/Reporter-App/2015-10-23-reporter-export.json", other); err != other {
+		t.Errorf("Expected other errors to be returned unchanged but got %v", err)
+	}
+}
+
+func TestFilesystemGetReportForTime(t *testing.T) {
+	date := time.Date(2015, time.October, 23, 0, 0, 0, 0, time.UTC)
+	for _, storageLocation := range []string{"./testData", "./testData/"} {
+		backend, err := NewFilesystemBackend(storageLocation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := backend.GetReportForTime(date); err != nil {
+			t.Errorf("Unable to get report for storage location %s: %s", storageLocation, err)
+		}
+	}
+}
+
+func TestDayBinaryRoundTrip(t *testing.T) {
+	for _, filePath := range []string{"./testData/2014-01-15-reporter-export.json", "./testData/2015-10-23-reporter-export.json", "./testData/photoset-array.json"} {
+		fileJSON, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		day, err := DecodeJSONString(string(fileJSON))
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, err := day.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Day
+		if err := decoded.UnmarshalBinary(encoded); err != nil {
+			t.Fatal(err)
+		}
+		expectedJSON, err := json.Marshal(day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decodedJSON, err := json.Marshal(decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(thingToMap(t, decodedJSON), thingToMap(t, expectedJSON)) {
+			t.Errorf("Day decoded from binary does NOT match the original for %s", filePath)
+		}
+	}
+}
+
+func TestAllSnapshots(t *testing.T) {
+	backend, err := NewFilesystemBackend("./testData")
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshots, errs := AllSnapshots(context.Background(), backend, 2)
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if len(snapshots) != 9 {
+		t.Errorf("Snapshot count does not match expected value! We were expecting 9 but got %d", len(snapshots))
+	}
+}
+
+func TestAllSnapshotsCancelled(t *testing.T) {
+	backend, err := NewFilesystemBackend("./testData")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	snapshots, errs := AllSnapshots(ctx, backend, 2)
+	if len(snapshots) != 0 {
+		t.Errorf("Expected no snapshots from a cancelled context but got %d", len(snapshots))
+	}
+	if len(errs) != 2 {
+		t.Fatalf("Error count does not match expected value! We were expecting 2 but got %d", len(errs))
+	}
+	for _, err := range errs {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled but got %s", err)
+		}
+	}
+}
+
+func TestAllSnapshotsFailingReport(t *testing.T) {
+	dir := t.TempDir()
+	contents, err := ioutil.ReadFile("./testData/2015-10-23-reporter-export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "2015-10-23-reporter-export.json"), contents, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "2015-10-24-reporter-export.json"), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	backend, err := NewFilesystemBackend(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshots, errs := AllSnapshots(context.Background(), backend, 2)
+	if len(snapshots) != 4 {
+		t.Errorf("Snapshot count does not match expected value! We were expecting 4 but got %d", len(snapshots))
+	}
+	if len(errs) != 1 {
+		t.Fatalf("Error count does not match expected value! We were expecting 1 but got %d", len(errs))
+	}
+	if !strings.HasPrefix(errs[0].Error(), "2015-10-24-reporter-export.json: ") {
+		t.Errorf("Expected the error to name the failing report but got %s", errs[0])
+	}
+}
+
+func TestDayRedact(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	day.Redact(RedactOptions{Location: true, Weather: true, Photos: true, Placemarks: true})
+	for _, snapshot := range day.Snapshots {
+		if snapshot.Location != nil {
+			t.Error("Expected snapshot location to be removed")
+		}
+		if snapshot.Weather != nil && (snapshot.Weather.Latitude != nil || snapshot.Weather.Longitude != nil) {
+			t.Error("Expected weather coordinates to be removed")
+		}
+		if snapshot.Battery == nil || snapshot.Steps == nil {
+			t.Error("Expected battery and steps to be left intact")
+		}
+	}
+}
+
+func TestDayClone(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	clone := day.Clone()
+	clone.Redact(RedactOptions{Location: true, Placemarks: true})
+	*clone.Snapshots[0].Battery = 0
+	if day.Snapshots[0].Location == nil || day.Snapshots[0].Location.Placemark.Name == "" {
+		t.Error("Expected the original day to keep its location data")
+	}
+	if *day.Snapshots[0].Battery == 0 {
+		t.Error("Expected the original battery to be left untouched")
+	}
+}
+
+type staticTimezoneResolver struct {
+	calls int
+}
+
+func (r *staticTimezoneResolver) TimezoneForLocation(ctx context.Context, timestamp int64, lat, long float64) (string, error) {
+	r.calls++
+	return "America/Los_Angeles", nil
+}
+
+// redirectingTransport sends every request to the host of target instead, so clients with hardcoded URLs can be tested against a local server
+type redirectingTransport struct {
+	target *url.URL
+}
+
+func (r *redirectingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	redirected := req.Clone(req.Context())
+	redirected.URL.Scheme = r.target.Scheme
+	redirected.URL.Host = r.target.Host
+	return http.DefaultTransport.RoundTrip(redirected)
+}
+
+func TestGoogleTimezoneResolverContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("location") == "0.000000,0.000000" {
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+			return
+		}
+		w.Write([]byte(`{"status":"OK","timeZoneId":"America/Los_Angeles"}`))
+	}))
+	defer server.Close()
+	defer close(release)
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver := &GoogleTimezoneResolver{Client: &http.Client{Transport: &redirectingTransport{target}}}
+	timeZone, err := resolver.TimezoneForLocation(context.Background(), 1445584230, 37.8, -122.26)
+	if err != nil || timeZone != "America/Los_Angeles" {
+		t.Errorf("Timezone does not match expected value! We were expecting America/Los_Angeles but got %s (%v)", timeZone, err)
+	}
+	expired, cancelExpired := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelExpired()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, test := range []struct {
+		ctx      context.Context
+		expected error
+	}{
+		{expired, context.DeadlineExceeded},
+		{cancelled, context.Canceled},
+	} {
+		start := time.Now()
+		_, err := resolver.TimezoneForLocation(test.ctx, 1445584230, 0, 0)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("Expected %v from the timezone lookup but got %v", test.expected, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("Expected the timezone lookup to return promptly but it took %s", elapsed)
+		}
+	}
+}
+
+func TestDayInferTimeZone(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	resolver := &staticTimezoneResolver{}
+	for i := 0; i < 2; i++ {
+		timeZone, err := day.InferTimeZone(resolver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if timeZone != "America/Los_Angeles" {
+			t.Errorf("Timezone does not match expected value! We were expecting America/Los_Angeles but got %s", timeZone)
+		}
+	}
+	if resolver.calls != 1 {
+		t.Errorf("Expected the timezone lookup to be cached but the resolver was called %d times", resolver.calls)
+	}
+}
+
+func TestSnapshotBatteryPercent(t *testing.T) {
+	for _, test := range []struct {
+		battery  float64
+		expected float64
+	}{
+		{0.38, 38},
+		{1, 100},
+		{90, 90},
+		{9000, 100},
+		{-0.5, 0},
+	} {
+		battery := test.battery
+		snapshot := Snapshot{Battery: &battery}
+		if percent, ok := snapshot.BatteryPercent(); !ok || math.Abs(percent-test.expected) > 1e-9 {
+			t.Errorf("Battery percent for %f does not match expected value! We were expecting %f but got %f", test.battery, test.expected, percent)
+		}
+	}
+	if _, ok := (&Snapshot{}).BatteryPercent(); ok {
+		t.Error("Expected no battery percent without a battery level")
+	}
+}
+
+func TestBatteryPercentages(t *testing.T) {
+	fileJSON, err := ioutil.ReadFile("./testData/battery-percentages.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day, err := DecodeJSONString(string(fileJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pacific := time.FixedZone("PDT", -7*60*60)
+	stats := day.AggregateByHour(pacific)
+	if math.Abs(stats[8].AverageBattery-0.75) > 1e-9 || math.Abs(stats[9].AverageBattery-0.62) > 1e-9 {
+		t.Errorf("Average battery does not match expected value! We were expecting 0.75 and 0.62 but got %f and %f", stats[8].AverageBattery, stats[9].AverageBattery)
+	}
+	_, values, err := day.TimeSeries("battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []float64{0.8, 0.7, 0.62}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("Battery time series does not match expected value! We were expecting %v but got %v", expected, values)
+	}
+	// Draining 18% in an hour is past the 15% an hour that scores 1, and 3 reports score 3/24
+	if score, expected := day.UsageProxy(), (0.5+0.25*3.0/24)/0.75; math.Abs(score-expected) > 1e-9 {
+		t.Errorf("Usage proxy does not match expected value! We were expecting %f but got %f", expected, score)
+	}
+	var influx bytes.Buffer
+	if err := day.WriteInfluxLine(&influx, "reporter"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(influx.String(), "battery=0.8 ") {
+		t.Errorf("Expected the battery to be written as a fraction but got %s", influx.String())
+	}
+	var csvOutput bytes.Buffer
+	if err := day.WriteCSV(&csvOutput); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(csvOutput.String(), ",80,") {
+		t.Errorf("Expected the battery to be written as a fraction but got %s", csvOutput.String())
+	}
+}
+
+func TestSnapshotIsOnline(t *testing.T) {
+	for _, test := range []struct {
+		connection string
+		expected   bool
+	}{
+		{"0", true},
+		{"1", true},
+		{"2", false},
+	} {
+		var connection ConnectionType
+		if err := json.Unmarshal([]byte(test.connection), &connection); err != nil {
+			t.Fatal(err)
+		}
+		if online := connection.IsOnline(); online != test.expected {
+			t.Errorf("IsOnline for %s does not match expected value! We were expecting %t but got %t", connection.Method, test.expected, online)
+		}
+		snapshot := Snapshot{Connection: &connection}
+		if online := snapshot.IsOnline(); online != test.expected {
+			t.Errorf("Snapshot IsOnline for %s does not match expected value! We were expecting %t but got %t", connection.Method, test.expected, online)
+		}
+	}
+	if (&Snapshot{}).IsOnline() {
+		t.Error("Expected a snapshot without a connection to not be online")
+	}
+}
+
+func TestSnapshotBestAltitudeMeters(t *testing.T) {
+	meters := func(value float64) *float64 { return &value }
+	gps := &Altitude{GPSAltitudeFromLocation: meters(20), GPSRawAltitude: meters(30)}
+	for _, test := range []struct {
+		name           string
+		snapshot       Snapshot
+		expected       float64
+		expectedSource string
+		expectedOk     bool
+	}{
+		{"every source", Snapshot{Location: &Location{Altitude: meters(10), VerticalAccuracy: meters(4)}, Altitude: gps}, 10, "location", true},
+		{"an invalid vertical accuracy", Snapshot{Location: &Location{Altitude: meters(10), VerticalAccuracy: meters(-1)}, Altitude: gps}, 20, "gpsAltitudeFromLocation", true},
+		{"no vertical accuracy", Snapshot{Location: &Location{Altitude: meters(10)}, Altitude: gps}, 20, "gpsAltitudeFromLocation", true},
+		{"only the raw GPS altitude", Snapshot{Location: &Location{}, Altitude: &Altitude{GPSRawAltitude: meters(30)}}, 30, "gpsRawAltitude", true},
+		{"no sources", Snapshot{Location: &Location{VerticalAccuracy: meters(4)}, Altitude: &Altitude{}}, 0, "", false},
+		{"nothing", Snapshot{}, 0, "", false},
+	} {
+		altitude, source, ok := test.snapshot.BestAltitudeMeters()
+		if ok != test.expectedOk || altitude != test.expected || source != test.expectedSource {
+			t.Errorf("Best altitude with %s does not match expected value! We were expecting %v from %q but got %v from %q", test.name, test.expected, test.expectedSource, altitude, source)
+		}
+	}
+}
+
+func TestSnapshotNilSafeGetters(t *testing.T) {
+	getters := map[string]func(*Snapshot) (float64, bool){
+		"TemperatureCelsius":   (*Snapshot).TemperatureCelsius,
+		"TemperatureFarenheit": (*Snapshot).TemperatureFarenheit,
+		"AudioAverageDb":       (*Snapshot).AudioAverageDb,
+		"AudioPeakDb":          (*Snapshot).AudioPeakDb,
+		"PressureMillibars":    (*Snapshot).PressureMillibars,
+		"Latitude": func(s *Snapshot) (float64, bool) {
+			lat, _, ok := s.Coordinates()
+			return lat, ok
+		},
+	}
+	for name, snapshot := range map[string]*Snapshot{
+		"a zero snapshot":               {},
+		"a snapshot with empty structs": {Weather: &Weather{}, Location: &Location{}, Audio: &Audio{}},
+	} {
+		for getter, get := range getters {
+			if value, ok := get(snapshot); ok || value != 0 {
+				t.Errorf("Expected %s of %s to not be ok but got %v", getter, name, value)
+			}
+		}
+	}
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	snapshot := &day.Snapshots[0]
+	expected := map[string]float64{
+		"TemperatureCelsius":   *snapshot.Weather.TemperatureCelsius,
+		"TemperatureFarenheit": *snapshot.Weather.TemperatureFarenheit,
+		"AudioAverageDb":       *snapshot.Audio.Average,
+		"AudioPeakDb":          *snapshot.Audio.Peak,
+		"PressureMillibars":    *snapshot.Weather.PressureMillibars,
+		"Latitude":             *snapshot.Location.Latitude,
+	}
+	for getter, get := range getters {
+		if value, ok := get(snapshot); !ok || value != expected[getter] {
+			t.Errorf("%s does not match expected value! We were expecting %v but got %v", getter, expected[getter], value)
+		}
+	}
+}
+
+func TestReportImpetusIsAutomatic(t *testing.T) {
+	for impetus, expected := range []bool{false, false, true, true, true} {
+		reportImpetus := ReportImpetus{Impetus: impetus}
+		if automatic := reportImpetus.IsAutomatic(); automatic != expected {
+			t.Errorf("IsAutomatic for impetus %d does not match expected value! We were expecting %t but got %t", impetus, expected, automatic)
+		}
+	}
+}
+
+func TestDayManualSnapshots(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	if snapshots := day.ManualSnapshots(); len(snapshots) != 0 {
+		t.Errorf("Expected no manual snapshots in a day of automatic reports but got %d", len(snapshots))
+	}
+	day.Snapshots[1].ReportImpetus = &ReportImpetus{Impetus: 0}
+	day.Snapshots[2].ReportImpetus = &ReportImpetus{Impetus: 1}
+	day.Snapshots[3].ReportImpetus = nil
+	snapshots := day.ManualSnapshots()
+	if len(snapshots) != 2 || snapshots[0].ID != day.Snapshots[1].ID || snapshots[1].ID != day.Snapshots[2].ID {
+		t.Errorf("Expected the two tapped reports to be returned in order but got %d snapshots", len(snapshots))
+	}
+	v1 := loadTestFile(t, "./testData/2014-01-15-reporter-export.json")
+	if snapshots := v1.ManualSnapshots(); len(snapshots) != 0 {
+		t.Errorf("Expected schema version 1 snapshots without an impetus to be left out but got %d", len(snapshots))
+	}
+}
+
+func TestLocationAltitudeFeet(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	location := day.Snapshots[0].Location
+	feet, ok := location.AltitudeFeet()
+	if expected := *location.Altitude * 3.28084; !ok || math.Abs(feet-expected) > 1e-9 {
+		t.Errorf("Altitude in feet does not match expected value! We were expecting %f but got %f", expected, feet)
+	}
+	meters := 100.0
+	if feet, ok := (&Location{Altitude: &meters}).AltitudeFeet(); !ok || math.Abs(feet-328.084) > 1e-9 {
+		t.Errorf("Altitude in feet does not match expected value! We were expecting 328.084 but got %f", feet)
+	}
+	if _, ok := (&Location{}).AltitudeFeet(); ok {
+		t.Error("Expected no altitude in feet without an altitude")
+	}
+}
+
+func TestSnapshotsSeq(t *testing.T) {
+	backend, err := NewFilesystemBackend("./testData")
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for _, err := range SnapshotsSeq(backend) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	if count != 3 {
+		t.Errorf("Expected to stop after 3 snapshots but got %d", count)
+	}
+}
+
+func TestDecodeDays(t *testing.T) {
+	versionOne, err := ioutil.ReadFile("./testData/2014-01-15-reporter-export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	versionTwo, err := ioutil.ReadFile("./testData/2015-10-23-reporter-export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	days, err := DecodeDays([]byte("[" + string(versionOne) + "," + string(versionTwo) + "]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 2 || days[0].SchemaVersion != 1 || days[1].SchemaVersion != 2 {
+		t.Errorf("Expected a version 1 and a version 2 day but got %d days", len(days))
+	}
+	days, err = DecodeDays(versionTwo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 1 || days[0].SchemaVersion != 2 {
+		t.Errorf("Expected a single version 2 day but got %d days", len(days))
+	}
+}
+
+func TestPlacemarkFormattedAddress(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	address := day.Snapshots[0].Location.Placemark.FormattedAddress()
+	if address != "320 23rd St, Oakland, CA 94612, United States" {
+		t.Errorf("Address does not match expected value! We got %s", address)
+	}
+	if address := (&Placemark{Locality: "Oakland", Country: "United States"}).FormattedAddress(); address != "Oakland, United States" {
+		t.Errorf("Address does not match expected value! We got %s", address)
+	}
+	if address := (&Placemark{}).FormattedAddress(); address != "" {
+		t.Errorf("Expected an empty address but got %s", address)
+	}
+}
+
+func TestDayAggregateByHour(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	stats := day.AggregateByHour(time.FixedZone("PDT", -7*60*60))
+	if len(stats) != 4 {
+		t.Errorf("Expected 4 hours with data but got %d", len(stats))
+	}
+	if stats[12].SnapshotCount != 1 || stats[12].TotalSteps != 1093 || stats[12].AverageBattery != 0.84 {
+		t.Errorf("Stats for noon do not match expected values! We got %+v", stats[12])
+	}
+}
+
+func TestDayWriteInfluxLine(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	var buf bytes.Buffer
+	if err := day.WriteInfluxLine(&buf, "reporter snapshots"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 lines but got %d", len(lines))
+	}
+	expected := `reporter\ snapshots battery=0.38,steps=278i,audio_avg=-47.93216,audio_peak=-40.14682,temp_c=16.6 1445584230000000000`
+	if lines[0] != expected {
+		t.Errorf("Line does not match expected value! We were expecting %s but got %s", expected, lines[0])
+	}
+}
+
+func TestDateForFilename(t *testing.T) {
+	expected := time.Date(2015, time.October, 23, 0, 0, 0, 0, time.UTC)
+	for _, filePath := range []string{
+		"2015-10-23-reporter-export.json",
+		"/Apps/Reporter-App/2015-10-23-reporter-export.json",
+		"https://x/2015-10-23-reporter-export.json?X-Amz-Signature=abc&X-Amz-Expires=60",
+		"https://x/Apps/2015-10-23-reporter-export.json#fragment",
+		"2015-10-23-reporter-export.json?dl=1",
+	} {
+		date, err := dateForFilename(filePath)
+		if err != nil {
+			t.Errorf("Unable to parse date from %s: %s", filePath, err)
+			continue
+		}
+		if !date.Equal(expected) {
+			t.Errorf("Date for %s does not match expected value! We got %s", filePath, date)
+		}
+	}
+}
+
+type staticWeatherProvider struct{}
+
+func (staticWeatherProvider) Historical(lat, lon float64, t time.Time) (*Weather, error) {
+	return &Weather{WeatherDescription: "Clear"}, nil
+}
+
+func TestSnapshotFillWeather(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	snapshot := day.Snapshots[0]
+	snapshot.Weather = nil
+	if err := snapshot.FillWeather(staticWeatherProvider{}); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Weather == nil || snapshot.Weather.WeatherDescription != "Clear" {
+		t.Error("Expected weather to be filled from the provider")
+	}
+	if err := (&Snapshot{}).FillWeather(staticWeatherProvider{}); err == nil {
+		t.Error("Expected an error filling weather for a snapshot without a location")
+	}
+	snapshot = day.Snapshots[1]
+	snapshot.Weather = nil
+	if err := snapshot.FillWeather(NopWeatherProvider{}); !errors.Is(err, ErrNoWeather) || snapshot.Weather != nil {
+		t.Errorf("Expected ErrNoWeather and no weather from NopWeatherProvider but got %v", err)
+	}
+}
+
+func TestDateTimeVersionOneStability(t *testing.T) {
+	type timestamps struct {
+		Snapshots []struct {
+			Date json.RawMessage `json:"date"`
+			Day  json.RawMessage `json:"day"`
+		} `json:"snapshots"`
+	}
+	fileJSON, err := ioutil.ReadFile("./testData/2014-01-15-reporter-export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range [][]byte{fileJSON, []byte(`{"snapshots":[{"date":411486520.294940,"day":411458400.0}]}`)} {
+		day, err := DecodeJSONString(string(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := json.Marshal(day)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var expected, actual timestamps
+		if err := json.Unmarshal(input, &expected); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(output, &actual); err != nil {
+			t.Fatal(err)
+		}
+		for i, snapshot := range expected.Snapshots {
+			if string(actual.Snapshots[i].Date) != string(snapshot.Date) || string(actual.Snapshots[i].Day) != string(snapshot.Day) {
+				t.Errorf("Timestamps were not stable! We were expecting %s/%s but got %s/%s", snapshot.Date, snapshot.Day, actual.Snapshots[i].Date, actual.Snapshots[i].Day)
+			}
+		}
+	}
+}
+
+func TestDayCompletionRate(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	rate, ok := day.CompletionRate()
+	if !ok || rate != 1 {
+		t.Errorf("Completion rate does not match expected value! We were expecting 1 but got %f", rate)
+	}
+	day = loadTestFile(t, "./testData/2014-01-15-reporter-export.json")
+	if _, ok := day.CompletionRate(); ok {
+		t.Error("Expected no completion rate for a version 1 day without questions")
+	}
+}
+
+func TestDayTotalDistanceMeters(t *testing.T) {
+	day := loadTestFile(t, "./testData/2014-01-15-reporter-export.json")
+	if accurate := day.SnapshotsWithAccuracyBetter(200); len(accurate) != 2 {
+		t.Errorf("Expected 2 snapshots with an accuracy of 200m or better but got %d", len(accurate))
+	}
+	all, filtered := day.TotalDistanceMeters(0), day.TotalDistanceMeters(200)
+	if all <= filtered || filtered <= 0 {
+		t.Errorf("Expected filtering by accuracy to reduce the distance but got %f unfiltered and %f filtered", all, filtered)
+	}
+}
+
+func TestSnapshotCalendarDay(t *testing.T) {
+	versionOne := loadTestFile(t, "./testData/2014-01-15-reporter-export.json")
+	for _, snapshot := range versionOne.Snapshots {
+		calendarDay, ok := snapshot.CalendarDay()
+		if !ok {
+			t.Fatal("Expected a calendar day for a version 1 snapshot")
+		}
+		year, month, day := snapshot.Day.Date()
+		if calendarDay.Year() != year || calendarDay.Month() != month || calendarDay.Day() != day || calendarDay.Hour() != 0 {
+			t.Errorf("Calendar day should be midnight of Day but got %s for %s", calendarDay, snapshot.Day.Time)
+		}
+	}
+	versionTwo := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	calendarDay, ok := versionTwo.Snapshots[1].CalendarDay()
+	if !ok || calendarDay.Format(ISO8601) != "2015-10-23T00:00:00-0700" {
+		t.Errorf("Calendar day should fall back to the day of Date but got %s", calendarDay)
+	}
+	if _, ok := (&Snapshot{}).CalendarDay(); ok {
+		t.Error("Expected no calendar day for a snapshot without Date or Day")
+	}
+}
+
+func TestDropboxSharedLinkBackend(t *testing.T) {
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	for _, name := range []string{"2014-01-15-reporter-export.json", "2015-10-23-reporter-export.json"} {
+		contents, err := ioutil.ReadFile("./testData/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write(contents); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("dl") != "1" {
+			t.Errorf("Expected the shared link to be downloaded with dl=1 but got %s", r.URL.RawQuery)
+		}
+		w.Write(archive.Bytes())
+	}))
+	defer server.Close()
+	backend, err := NewDropboxSharedLinkBackend(server.URL + "/sh/abc123/AAAbbbCCC?dl=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := backend.ListReports()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Expected 2 reports but got %d", len(files))
+	}
+	latest, err := backend.GetLatestReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Name != "2015-10-23-reporter-export.json" || latest.Contents == "" {
+		t.Errorf("Expected the latest report to be 2015-10-23 but got %s", latest.Name)
+	}
+}
+
+func TestDayReassignIDs(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	shared := day.Snapshots[0].Location
+	day.Snapshots[1].Location = shared
+	originalID := shared.ID
+	day.ReassignIDs()
+	if shared.ID == originalID || shared.ID == "" {
+		t.Errorf("Expected location ID %s to be reassigned", originalID)
+	}
+	if day.Snapshots[1].Location.ID != day.Snapshots[0].Location.ID {
+		t.Error("Expected a shared location to keep a single ID")
+	}
+	if day.Snapshots[0].ID == "5E51B864-D2A5-479D-B676-B0C10E1BB354" {
+		t.Error("Expected snapshot ID to be reassigned")
+	}
+}
+
+func TestDateTimeISO8601Variants(t *testing.T) {
+	for input, expected := range map[string]time.Time{
+		"2015-10-23T14:02:05-0700":      time.Date(2015, time.October, 23, 21, 2, 5, 0, time.UTC),
+		"2015-10-23T14:02:05.123Z":      time.Date(2015, time.October, 23, 14, 2, 5, 123000000, time.UTC),
+		"2015-10-23T14:02:05Z":          time.Date(2015, time.October, 23, 14, 2, 5, 0, time.UTC),
+		"2015-10-23T14:02:05-07:00":     time.Date(2015, time.October, 23, 21, 2, 5, 0, time.UTC),
+		"2015-10-23T14:02:05.5-07:00":   time.Date(2015, time.October, 23, 21, 2, 5, 500000000, time.UTC),
+		"2015-10-23T14:02:05.123-0700":  time.Date(2015, time.October, 23, 21, 2, 5, 123000000, time.UTC),
+		"2015-10-23T14:02:05.123+00:00": time.Date(2015, time.October, 23, 14, 2, 5, 123000000, time.UTC),
+	} {
+		var dateTime DateTime
+		if err := json.Unmarshal([]byte(`"`+input+`"`), &dateTime); err != nil {
+			t.Errorf("Unable to parse %s: %s", input, err)
+			continue
+		}
+		if !dateTime.Equal(expected) {
+			t.Errorf("Timestamp %s does not match expected value! We got %s", input, dateTime.Time)
+		}
+		output, err := json.Marshal(&dateTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(output) != `"`+input+`"` {
+			t.Errorf("Timestamp %s was not written back out unchanged, we got %s", input, output)
+		}
+	}
+}
+
+func TestFilesystemConflictedCopies(t *testing.T) {
+	contents, err := ioutil.ReadFile("./testData/2015-10-23-reporter-export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storageLocation := t.TempDir()
+	modified := time.Date(2015, time.October, 24, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{
+		"2015-10-23-reporter-export.json",
+		"2015-10-23-reporter-export (conflicted copy 2015-10-24).json",
+		"2015-10-23-reporter-export (Robbie's conflicted copy 2015-10-25).json",
+	} {
+		filePath := filepath.Join(storageLocation, name)
+		if err := ioutil.WriteFile(filePath, contents, 0644); err != nil {
+			t.Fatal(err)
+		}
+		fileTime := modified.Add(time.Duration(i) * time.Hour)
+		if err := os.Chtimes(filePath, fileTime, fileTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backend, err := NewFilesystemBackend(storageLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := backend.ListReports()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conflicted := 0
+	for _, file := range files {
+		if !file.TimeFromFilename.Equal(time.Date(2015, time.October, 23, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("Date for %s does not match expected value! We got %s", file.Name, file.TimeFromFilename)
+		}
+		if file.ConflictedCopy {
+			conflicted++
+		}
+	}
+	if len(files) != 3 || conflicted != 2 {
+		t.Errorf("Expected 3 reports with 2 conflicted copies but got %d reports with %d conflicted copies", len(files), conflicted)
+	}
+	latest, err := backend.GetLatestReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Name != "2015-10-23-reporter-export (Robbie's conflicted copy 2015-10-25).json" {
+		t.Errorf("Expected the most recently modified copy to be the latest report but got %s", latest.Name)
+	}
+}
+
+func TestDecodeQuotedNumbers(t *testing.T) {
+	fileJSON, err := ioutil.ReadFile("./testData/quoted-numbers.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day, err := DecodeJSONString(string(fileJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := day.Snapshots[0]
+	if snapshot.Battery == nil || *snapshot.Battery != 0.9 || snapshot.Steps == nil || *snapshot.Steps != 120 {
+		t.Error("Expected quoted battery and steps to be decoded as numbers")
+	}
+	if lat, long, ok := snapshot.Coordinates(); !ok || lat != 37.81186274221337 || long != -122.2645512409341 {
+		t.Error("Expected quoted coordinates to be decoded as numbers")
+	}
+	if temperature, ok := snapshot.TemperatureFarenheit(); !ok || temperature != 61.8 {
+		t.Error("Expected quoted temperature to be decoded as a number")
+	}
+	output, err := json.Marshal(day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if battery := thingToMap(t, output)["snapshots"].([]interface{})[0].(map[string]interface{})["battery"]; battery != 0.9 {
+		t.Errorf("Expected battery to be written back out as a number but got %v", battery)
+	}
+	// Numbers Go can parse but that aren't valid JSON numbers are rewritten
+	snapshot = day.Snapshots[1]
+	if snapshot.Battery == nil || *snapshot.Battery != 0.9 || snapshot.Steps == nil || *snapshot.Steps != 100 {
+		t.Error("Expected a signed battery and an exponent step count to be decoded as numbers")
+	}
+	if lat, long, ok := snapshot.Coordinates(); !ok || lat != 0.5 || long != -0.25 {
+		t.Errorf("Expected coordinates without leading zeros to be decoded as numbers but got %v, %v", lat, long)
+	}
+	if temperature, ok := snapshot.TemperatureCelsius(); !ok || temperature != 0.25 {
+		t.Errorf("Expected a hex float temperature to be decoded as a number but got %v", temperature)
+	}
+	for _, value := range []string{"NaN", "Inf", "-Infinity"} {
+		_, err := DecodeJSONString(`{"snapshots":[{"date":"2015-10-23T00:10:30-0700","battery":"` + value + `"}]}`)
+		if err == nil || !strings.Contains(err.Error(), "Unable to decode") {
+			t.Errorf("Expected a clear error for a battery of %s but got %v", value, err)
+		}
+	}
+}
+
+func TestDayString(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	if summary := day.String(); summary != "2015-10-23: 4 snapshots (schema version 2) from 00:10 to 15:05" {
+		t.Errorf("Day summary does not match expected value! We got %s", summary)
+	}
+}
+
+func TestDayUpdateSnapshot(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	updated := day.UpdateSnapshot("96B962E5-DF0D-4C0C-B1D2-0B1D0E1D8B1C", func(s *Snapshot) {})
+	if updated {
+		t.Error("Expected no snapshot to match an unknown ID")
+	}
+	updated = day.UpdateSnapshot("5E51B864-D2A5-479D-B676-B0C10E1BB354", func(s *Snapshot) {
+		battery := 0.5
+		s.Battery = &battery
+	})
+	if !updated {
+		t.Fatal("Expected the snapshot to be updated")
+	}
+	output, err := json.Marshal(day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if battery := thingToMap(t, output)["snapshots"].([]interface{})[0].(map[string]interface{})["battery"]; battery != 0.5 {
+		t.Errorf("Battery does not match expected value! We were expecting 0.5 but got %v", battery)
+	}
+}
+
+func TestDecoderOptions(t *testing.T) {
+	input := []byte(`{"snapshots":[{"date":"2015-10-23T00:10:30-0700","newField":1}]}`)
+	if _, err := NewDecoder(DecodeOptions{Strict: true}).Decode(input); err == nil {
+		t.Error("Expected a strict decoder to reject unknown fields")
+	}
+	day, err := NewDecoder(DecodeOptions{SchemaVersion: 1, Location: time.UTC}).Decode(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if day.SchemaVersion != 1 {
+		t.Errorf("Expected the schema version to be forced to 1 but got %d", day.SchemaVersion)
+	}
+	if day.Snapshots[0].Date.Location() != time.UTC || day.Snapshots[0].Date.Hour() != 7 {
+		t.Errorf("Expected the snapshot date to be converted to UTC but got %s", day.Snapshots[0].Date.Time)
+	}
+}
+
+func TestDecoderStrictNestedFields(t *testing.T) {
+	input := []byte(`{"snapshots":[{"date":"2015-10-23T00:10:30-0700","weather":{"tempF":50,"newField":1},"responses":[{"questionPrompt":"Where are you?","locationResponse":{"text":"Home","newField":2}}]}]}`)
+	_, err := NewDecoder(DecodeOptions{Strict: true}).Decode(input)
+	if err == nil {
+		t.Fatal("Expected a strict decoder to reject unknown nested fields")
+	}
+	expected := "Unknown fields: snapshots[0].responses[0].locationResponse.newField, snapshots[0].weather.newField"
+	if err.Error() != expected {
+		t.Errorf("Strict error does not match expected value! We were expecting %q but got %q", expected, err.Error())
+	}
+	for _, name := range []string{"2014-01-15-reporter-export.json", "2015-10-23-reporter-export.json", "photoset-array.json", "region-formats.json"} {
+		contents, err := ioutil.ReadFile(filepath.Join("testData", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewDecoder(DecodeOptions{Strict: true}).Decode(contents); err != nil {
+			t.Errorf("Expected a strict decoder to accept %s but got %s", name, err)
+		}
+	}
+}
+
+func TestDayTemperatureTrend(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	start, end, delta, ok := day.TemperatureTrend()
+	if !ok || start != 16.6 || end != 23.2 || math.Abs(delta-6.6) > 1e-9 {
+		t.Errorf("Temperature trend does not match expected value! We got %f to %f (%f)", start, end, delta)
+	}
+	if _, _, delta, ok := day.PressureTrend(); !ok || delta != 1 {
+		t.Errorf("Pressure trend does not match expected value! We were expecting 1 but got %f", delta)
+	}
+	if _, _, _, ok := (&Day{Snapshots: day.Snapshots[:1]}).TemperatureTrend(); ok {
+		t.Error("Expected no temperature trend for a single snapshot")
+	}
+}
+
+func TestFSBackend(t *testing.T) {
+	backend, err := NewFSBackend(os.DirFS("."), "testData")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := backend.ListReports()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Expected 2 reports but got %d", len(files))
+	}
+	day, err := LatestDay(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(day.Snapshots) != 4 || day.FileInfo.Source != "fs" {
+		t.Errorf("Expected the 2015-10-23 report from the fs backend but got %s", day)
+	}
+	if _, err := backend.GetReportForTime(time.Date(2014, time.January, 15, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDayCadenceWindows(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	windows := day.CadenceWindows()
+	if len(windows) != 3 {
+		t.Fatalf("Expected 3 cadence windows but got %d", len(windows))
+	}
+	if windows[0].StepsPerMinute != 0 {
+		t.Errorf("Cadence does not match expected value! We were expecting 0 but got %f", windows[0].StepsPerMinute)
+	}
+	expected := 1093 / (157 + 9.0/60)
+	if math.Abs(windows[1].StepsPerMinute-expected) > 1e-9 {
+		t.Errorf("Cadence does not match expected value! We were expecting %f but got %f", expected, windows[1].StepsPerMinute)
+	}
+	if !windows[2].End.Equal(day.Snapshots[3].Date.Time) {
+		t.Errorf("Cadence window end does not match expected value! We were expecting %s but got %s", day.Snapshots[3].Date.Time, windows[2].End)
+	}
+	day.Snapshots[2].Steps = nil
+	if windows := day.CadenceWindows(); len(windows) != 1 {
+		t.Errorf("Expected windows touching a snapshot without steps to be skipped, but got %d windows", len(windows))
+	}
+	v1 := loadTestFile(t, "./testData/2014-01-15-reporter-export.json")
+	if windows := v1.CadenceWindows(); len(windows) != 0 {
+		t.Errorf("Expected windows of schema version 1 snapshots without steps to be skipped, but got %d windows", len(windows))
+	}
+}
+
+func TestSnapshotIsDaylight(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	snapshot := day.Snapshots[0]
+	// Sunrise in Oakland on 2015-10-23 was at 07:24 and sunset at 18:22
+	pacific := time.FixedZone("PDT", -7*60*60)
+	for clock, expected := range map[string]bool{"00:10": false, "07:15": false, "07:35": true, "12:28": true, "18:15": true, "18:30": false} {
+		reportTime, _ := time.ParseInLocation("2006-01-02 15:04", "2015-10-23 "+clock, pacific)
+		snapshot.Date = &DateTime{Time: reportTime}
+		daylight, ok := snapshot.IsDaylight()
+		if !ok || daylight != expected {
+			t.Errorf("IsDaylight does not match expected value at %s! We were expecting %t but got %t", clock, expected, daylight)
+		}
+	}
+	snapshot.Location = nil
+	if _, ok := snapshot.IsDaylight(); ok {
+		t.Error("Expected IsDaylight to not be ok without coordinates")
+	}
+}
+
+func TestDayDiff(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	if diffs := day.Diff(day.Clone()); len(diffs) != 0 {
+		t.Errorf("Expected no differences between a day and its clone but got %v", diffs)
+	}
+	other := day.Clone()
+	steps := 300
+	other.Snapshots[0].Steps = &steps
+	other.Snapshots[1].Weather = nil
+	other.Snapshots = other.Snapshots[:3]
+	other.Snapshots = append(other.Snapshots, Snapshot{ID: "NEW"})
+	expected := []string{
+		"snapshot " + day.Snapshots[0].ID + ": steps changed from 278 to 300",
+		"snapshot " + day.Snapshots[1].ID + ": weather removed",
+		"snapshot " + day.Snapshots[3].ID + " removed",
+		"snapshot NEW added",
+	}
+	if diffs := day.Diff(other); !reflect.DeepEqual(diffs, expected) {
+		t.Errorf("Diff does not match expected value! We were expecting %v but got %v", expected, diffs)
+	}
+	versionOne := loadTestFile(t, "./testData/2014-01-15-reporter-export.json")
+	if diffs := versionOne.Diff(versionOne.Clone()); len(diffs) != 0 {
+		t.Errorf("Expected no differences between a schema version 1 day and its clone but got %v", diffs)
+	}
+}
+
+func TestFieldUnits(t *testing.T) {
+	units := FieldUnits()
+	for field, expected := range map[string]string{"battery": "fraction", "audio.avg": "dB", "weather.tempC": "°C", "location.altitude": "m"} {
+		if units[field] != expected {
+			t.Errorf("Unit for %s does not match expected value! We were expecting %s but got %s", field, expected, units[field])
+		}
+	}
+	units["battery"] = "%"
+	if FieldUnits()["battery"] != "fraction" {
+		t.Error("Expected FieldUnits to return a copy")
+	}
+	// Every unit should belong to a numeric field that actually exists
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	for field := range units {
+		if !strings.HasPrefix(field, "photoSet.") && !strings.HasPrefix(field, "altitude.") && !snapshotHasField(t, day.Snapshots, field) {
+			t.Errorf("No snapshot has the field %s", field)
+		}
+	}
+}
+
+// snapshotHasField returns true if any of the snapshots has a value at the dotted JSON path
+func snapshotHasField(t *testing.T, snapshots []Snapshot, field string) bool {
+	for _, snapshot := range snapshots {
+		snapshotJSON, err := json.Marshal(snapshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var value interface{} = thingToMap(t, snapshotJSON)
+		for _, key := range strings.Split(field, ".") {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				value = nil
+				break
+			}
+			value = object[key]
+		}
+		if value != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDayAllPhotosByTakenAt(t *testing.T) {
+	day := loadTestFile(t, "./testData/2014-01-15-reporter-export.json")
+	photos := day.AllPhotosByTakenAt()
+	if len(photos) != 5 {
+		t.Fatalf("Photo list length does not match expected value! We were expecting 5 but got %d", len(photos))
+	}
+	// The first snapshot's photos were filed out of shutter order
+	unsorted := day.AllPhotos()
+	if photos[0].AssetURL != unsorted[1].AssetURL || photos[1].AssetURL != unsorted[0].AssetURL {
+		t.Errorf("Expected the first two photos to be swapped but got %s and %s", photos[0].AssetURL, photos[1].AssetURL)
+	}
+	for i := 1; i < len(photos); i++ {
+		previous, _ := photos[i-1].TakenAt()
+		current, _ := photos[i].TakenAt()
+		if current.Before(previous) {
+			t.Errorf("Photo %d was taken at %s, before the previous photo at %s", i, current, previous)
+		}
+	}
+	if _, ok := (&Photo{}).TakenAt(); ok {
+		t.Error("Expected TakenAt to not be ok without a DateTime")
+	}
+}
+
+func TestDecodeEmptyReport(t *testing.T) {
+	for _, contents := range []string{"", "   \n"} {
+		if _, err := DecodeJSONString(contents); err != ErrEmptyReport {
+			t.Errorf("Expected ErrEmptyReport for %q but got %v", contents, err)
+		}
+		if _, err := DecodeFile(File{Name: "2015-10-23-reporter-export.json", Contents: contents}); err != ErrEmptyReport {
+			t.Errorf("Expected ErrEmptyReport from DecodeFile for %q but got %v", contents, err)
+		}
+	}
+}
+
+func TestFilesystemBackendSkipsEmptyReports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	contents, err := ioutil.ReadFile("./testData/2015-10-23-reporter-export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "2015-10-23-reporter-export.json"), contents, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "2015-10-24-reporter-export.json"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	backend, err := NewFilesystemBackend(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err := backend.GetLatestReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Name != "2015-10-23-reporter-export.json" {
+		t.Errorf("Latest report does not match expected value! We were expecting 2015-10-23-reporter-export.json but got %s", latest.Name)
+	}
+}
+
+func TestFilesystemBackendOnlyEmptyReports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "2015-10-23-reporter-export.json"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	backend, err := NewFilesystemBackend(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backend.GetLatestReport(); err == nil {
+		t.Error("Expected an error for a directory with only empty reports")
+	}
+}
+
+func TestFilesystemBackendOnlyUnparseableReports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "2015-13-45-reporter-export.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	backend, err := NewFilesystemBackend(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := &recordingLogger{}
+	backend.Logger = logger
+	if _, err := backend.GetLatestReport(); err == nil {
+		t.Error("Expected an error for a directory with only unparseable report names")
+	}
+	if len(logger.errors) != 1 {
+		t.Errorf("Expected the unparseable name to be logged but got %v", logger.errors)
+	}
+}
+
+func TestDayForEachSnapshot(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	visited := 0
+	day.ForEachSnapshot(func(i int, snapshot *Snapshot) bool {
+		visited++
+		snapshot.SectionIdentifier = "edited"
+		return i < 1
+	})
+	if visited != 2 {
+		t.Errorf("Expected iteration to stop after 2 snapshots but visited %d", visited)
+	}
+	if day.Snapshots[1].SectionIdentifier != "edited" || day.Snapshots[2].SectionIdentifier == "edited" {
+		t.Error("Expected only the visited snapshots to be edited in place")
+	}
+}
+
+func TestRegionContains(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	region := day.Snapshots[0].Location.Placemark.Region
+	if !region.Contains(region.Latitude, region.Longitude) {
+		t.Error("Expected the region to contain its own center")
+	}
+	// One degree of latitude is roughly 111,195 meters
+	metersPerDegree := earthRadiusMeters * math.Pi / 180
+	if !region.Contains(region.Latitude+(region.Radius-1)/metersPerDegree, region.Longitude) {
+		t.Error("Expected the region to contain a point just inside its radius")
+	}
+	if region.Contains(region.Latitude+(region.Radius+1)/metersPerDegree, region.Longitude) {
+		t.Error("Expected the region to not contain a point just outside its radius")
+	}
+	if lat, long, _ := day.Snapshots[0].Coordinates(); !region.Contains(lat, long) {
+		t.Error("Expected the snapshot to be inside its own reported region")
+	}
+}
+
+func TestDayJSON(t *testing.T) {
+	day := loadTestFile(t, "./testData/2014-01-15-reporter-export.json")
+	// Marshal with a different global schema version to make sure the day's own version is used
+	previousVersion := SchemaVersion
+	SchemaVersion = 2
+	defer func() { SchemaVersion = previousVersion }()
+	compact, err := day.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileJSON, err := ioutil.ReadFile("./testData/2014-01-15-reporter-export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(thingToMap(t, compact), thingToMap(t, fileJSON)) {
+		t.Error("Day JSON does not match the schema version 1 test file")
+	}
+	indented, err := day.JSONIndent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(indented, []byte("\n  \"snapshots\"")) || !reflect.DeepEqual(thingToMap(t, indented), thingToMap(t, compact)) {
+		t.Error("Indented day JSON does not match expected value")
+	}
+}
+
+func TestWriteCSVStream(t *testing.T) {
+	versionOne := loadTestFile(t, "./testData/2014-01-15-reporter-export.json")
+	versionTwo := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	days := make(chan Day)
+	go func() {
+		days <- versionOne
+		days <- versionTwo
+		close(days)
+	}()
+	var buf bytes.Buffer
+	if err := WriteCSVStream(&buf, days); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := 1 + len(versionOne.Snapshots) + len(versionTwo.Snapshots)
+	if len(lines) != expected {
+		t.Fatalf("CSV line count does not match expected value! We were expecting %d but got %d", expected, len(lines))
+	}
+	if lines[0] != strings.Join(csvHeader, ",") {
+		t.Errorf("CSV header does not match expected value! We got %s", lines[0])
+	}
+	if !strings.HasPrefix(lines[len(lines)-1], "2015-10-23T15:05:00-07:00,") {
+		t.Errorf("Last CSV row does not match expected value! We got %s", lines[len(lines)-1])
+	}
+	var single bytes.Buffer
+	if err := versionTwo.WriteCSV(&single); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(buf.String(), strings.Join(strings.Split(single.String(), "\n")[1:], "\n")) {
+		t.Error("Expected the streamed rows to match the rows written by WriteCSV")
+	}
+}
+
+func TestResponseNormalizedOptions(t *testing.T) {
+	response := Response{AnsweredOptions: []string{" Coffee", "tea", "coffee ", "", "  ", "Tea", "apple\n", "COFFEE"}}
+	expected := []string{"apple", "Coffee", "tea"}
+	if options := response.NormalizedOptions(); !reflect.DeepEqual(options, expected) {
+		t.Errorf("Normalized options do not match expected value! We were expecting %v but got %v", expected, options)
+	}
+	if options := (&Response{}).NormalizedOptions(); len(options) != 0 {
+		t.Errorf("Expected no normalized options but got %v", options)
+	}
+}
+
+func TestDecodeStdin(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = reader
+	defer func() { os.Stdin = stdin }()
+	contents, err := ioutil.ReadFile("./testData/2015-10-23-reporter-export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		writer.Write(contents)
+		writer.Close()
+	}()
+	day, err := DecodeStdin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(day.Snapshots) != 4 || day.FileInfo.Source != "stdin" {
+		t.Errorf("Expected 4 snapshots from stdin but got %s from %s", day, day.FileInfo.Source)
+	}
+	if _, err := DecodeReader(strings.NewReader(" \n")); !errors.Is(err, ErrEmptyReport) {
+		t.Errorf("Expected ErrEmptyReport for empty input but got %v", err)
+	}
+}
+
+func TestSortSnapshotsTiebreak(t *testing.T) {
+	instant := &DateTime{Time: time.Date(2015, time.October, 23, 12, 0, 0, 0, time.UTC)}
+	snapshots := []Snapshot{
+		{ID: "B", Date: instant},
+		{SectionIdentifier: "2", Date: instant},
+		{ID: "A", Date: instant},
+		{},
+		{SectionIdentifier: "1", Date: instant},
+	}
+	reversed := make([]Snapshot, len(snapshots))
+	for i, snapshot := range snapshots {
+		reversed[len(snapshots)-1-i] = snapshot
+	}
+	sortSnapshots(snapshots)
+	sortSnapshots(reversed)
+	if !reflect.DeepEqual(snapshots, reversed) {
+		t.Error("Expected snapshots sharing a date to sort the same regardless of their original order")
+	}
+	var order []string
+	for _, snapshot := range snapshots {
+		order = append(order, snapshot.ID+snapshot.SectionIdentifier)
+	}
+	expected := []string{"1", "2", "A", "B", ""}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Snapshot order does not match expected value! We were expecting %v but got %v", expected, order)
+	}
+}
+
+func TestDecoderSkipFields(t *testing.T) {
+	contents, err := ioutil.ReadFile("./testData/2014-01-15-reporter-export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := DecodeJSONString(string(contents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	skimmed, err := NewDecoder(DecodeOptions{SkipPhotos: true, SkipWeather: true, SkipResponses: true}).Decode(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skimmed.Snapshots) != len(full.Snapshots) {
+		t.Fatalf("Expected %d snapshots but got %d", len(full.Snapshots), len(skimmed.Snapshots))
+	}
+	for i, snapshot := range skimmed.Snapshots {
+		if snapshot.PhotoSet != nil || snapshot.Weather != nil || snapshot.Responses != nil {
+			t.Errorf("Expected the heavy fields of snapshot %d to be skipped", i)
+		}
+		if *snapshot.Battery != *full.Snapshots[i].Battery || !snapshot.Date.Equal(full.Snapshots[i].Date.Time) {
+			t.Errorf("Expected the other fields of snapshot %d to be decoded", i)
+		}
+	}
+	if skimmed.SchemaVersion != 1 {
+		t.Errorf("Schema version does not match expected value! We were expecting 1 but got %d", skimmed.SchemaVersion)
+	}
+	if weatherOnly, err := NewDecoder(DecodeOptions{SkipWeather: true}).Decode(contents); err != nil || weatherOnly.PhotoCount() != full.PhotoCount() {
+		t.Errorf("Expected only weather to be skipped, but got %d photos (%v)", weatherOnly.PhotoCount(), err)
+	}
+	if again, err := DecodeJSONString(string(contents)); err != nil || again.Snapshots[0].Weather == nil {
+		t.Error("Expected the default decoder to decode every field after a skipping decode")
+	}
+}
+
+func TestDayMostVisitedPlacemark(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	placemark, count, ok := day.MostVisitedPlacemark()
+	if !ok || placemark.Name != "320 23rd St" || count != 3 {
+		t.Errorf("Most visited placemark does not match expected value! We were expecting 320 23rd St 3 times but got %v %d times", placemark, count)
+	}
+	tied := Day{Snapshots: []Snapshot{
+		{},
+		{Location: &Location{Placemark: &Placemark{Locality: "Oakland", Name: "2228 Broadway"}}},
+		{Location: &Location{}},
+		{Location: &Location{Placemark: &Placemark{Locality: "Oakland", Name: "320 23rd St"}}},
+	}}
+	if placemark, count, ok := tied.MostVisitedPlacemark(); !ok || placemark.Name != "2228 Broadway" || count != 1 {
+		t.Errorf("Expected the earliest placemark to win a tie but got %v", placemark)
+	}
+	if _, _, ok := (&Day{}).MostVisitedPlacemark(); ok {
+		t.Error("Expected no most visited placemark for a day without placemarks")
+	}
+}
+
+func TestResponsePlaceText(t *testing.T) {
+	day := loadTestFile(t, "./testData/2014-01-15-reporter-export.json")
+	var response *Response
+	for _, candidate := range day.Snapshots[0].Responses {
+		if candidate.Location != nil {
+			response = candidate
+		}
+	}
+	if response == nil {
+		t.Fatal("Expected the first snapshot to have a location response")
+	}
+	if text := response.PlaceText(); text != "Subway Station" {
+		t.Errorf("Place text does not match expected value! We were expecting Subway Station but got %s", text)
+	}
+	response.Location.Text = " "
+	response.Location.Location.Placemark = &Placemark{Name: "Caltrain"}
+	if text := response.PlaceText(); text != "Caltrain" {
+		t.Errorf("Place text does not match expected value! We were expecting Caltrain but got %s", text)
+	}
+	response.Location.Location = nil
+	if text := response.PlaceText(); text != "4c02dfdb0d0e0f47c9cb019a" {
+		t.Errorf("Place text does not match expected value! We were expecting 4c02dfdb0d0e0f47c9cb019a but got %s", text)
+	}
+	if text := (&Response{}).PlaceText(); text != "" {
+		t.Errorf("Expected no place text without a location response but got %s", text)
+	}
+}
+
+func TestMaxReportBytes(t *testing.T) {
+	backend, err := NewFilesystemBackend("./testData")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.MaxReportBytes = 1024
+	if _, err := backend.GetLatestReport(); !errors.Is(err, ErrReportTooLarge) {
+		t.Errorf("Expected ErrReportTooLarge from the filesystem backend but got %v", err)
+	}
+	if _, err := backend.GetReportForPath("./testData/2015-10-23-reporter-export.json"); !errors.Is(err, ErrReportTooLarge) {
+		t.Errorf("Expected ErrReportTooLarge from the filesystem backend but got %v", err)
+	}
+	fsBackend, err := NewFSBackend(os.DirFS("./testData"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsBackend.MaxReportBytes = 1024
+	if _, err := fsBackend.GetLatestReport(); !errors.Is(err, ErrReportTooLarge) {
+		t.Errorf("Expected ErrReportTooLarge from the fs backend but got %v", err)
+	}
+	fsBackend.MaxReportBytes = 0
+	if _, err := fsBackend.GetLatestReport(); err != nil {
+		t.Errorf("Expected the default limit to allow the report but got %v", err)
+	}
+}
+
+func TestDayTags(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	expected := []string{"Yes", "No", "Lunch", "Working"}
+	if tags := day.Tags(); !reflect.DeepEqual(tags, expected) {
+		t.Errorf("Tags do not match expected value! We were expecting %v but got %v", expected, tags)
+	}
+	day.Snapshots[3].Responses = append(day.Snapshots[3].Responses, &Response{Tokens: []*Token{{Text: " working"}, {Text: "gym"}}})
+	expected = []string{"Yes", "No", "Working", "gym", "Lunch"}
+	if tags := day.Tags(); !reflect.DeepEqual(tags, expected) {
+		t.Errorf("Tags do not match expected value! We were expecting %v but got %v", expected, tags)
+	}
+}
+
+func TestRoundTripEqual(t *testing.T) {
+	for _, filePath := range []string{"./testData/2014-01-15-reporter-export.json", "./testData/2015-10-23-reporter-export.json"} {
+		contents, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if equal, err := RoundTripEqual(contents); !equal || err != nil {
+			t.Errorf("Expected %s to round trip but got %v", filePath, err)
+		}
+	}
+	// An explicit null is dropped when re-encoding
+	equal, err := RoundTripEqual([]byte(`{"snapshots":[{"battery":0.5,"steps":null}]}`))
+	if equal || err == nil || !strings.Contains(err.Error(), "snapshots.0.steps removed") {
+		t.Errorf("Expected the dropped field to be reported but got %v", err)
+	}
+	if _, err := RoundTripEqual([]byte("{")); err == nil {
+		t.Error("Expected an error for invalid JSON")
+	}
+}
+
+func TestPhotoSetArray(t *testing.T) {
+	fileJSON, err := ioutil.ReadFile("./testData/photoset-array.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day, err := DecodeJSONString(string(fileJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if photos := day.AllPhotos(); len(photos) != 3 || photos[2].FocalLength == nil {
+		t.Fatalf("Expected 3 photos from both photo sets but got %d", len(photos))
+	}
+	if day.Snapshots[0].PhotoSet.ID != "0F4B7C2E-8D1A-4E7B-A1C3-5B9E2D6F7A01" {
+		t.Errorf("Photo set ID does not match expected value! We got %s", day.Snapshots[0].PhotoSet.ID)
+	}
+	if equal, err := RoundTripEqual(fileJSON); !equal {
+		t.Errorf("Expected the photo set array to be written back as an array but got %v", err)
+	}
+	// A single photo set object is still written back as an object
+	versionTwo := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	versionTwo.Snapshots[0].PhotoSet = &PhotoSet{Photos: []Photo{{AssetURL: "assets-library://asset/asset.JPG"}}}
+	snapshotJSON, err := json.Marshal(versionTwo.Snapshots[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := thingToMap(t, snapshotJSON)["photoSet"].(map[string]interface{}); !ok {
+		t.Error("Expected a single photo set to be written as an object")
+	}
+}
+
+func TestWeatherVisibilityCategory(t *testing.T) {
+	kilometers := func(value float64) *float64 { return &value }
+	for _, test := range []struct {
+		weather  Weather
+		expected string
+	}{
+		{Weather{VisibilityKilometers: kilometers(0)}, "Fog"},
+		{Weather{VisibilityKilometers: kilometers(0.4)}, "Fog"},
+		{Weather{VisibilityKilometers: kilometers(0.999)}, "Fog"},
+		{Weather{VisibilityKilometers: kilometers(1)}, "Haze"},
+		{Weather{VisibilityKilometers: kilometers(3)}, "Haze"},
+		{Weather{VisibilityKilometers: kilometers(4.999)}, "Haze"},
+		{Weather{VisibilityKilometers: kilometers(5)}, "Moderate"},
+		{Weather{VisibilityKilometers: kilometers(9.999)}, "Moderate"},
+		{Weather{VisibilityKilometers: kilometers(10)}, "Clear"},
+		{Weather{VisibilityKilometers: kilometers(16.1)}, "Clear"},
+		{Weather{VisibilityMiles: kilometers(2)}, "Haze"},
+		{Weather{VisibilityMiles: kilometers(0.62)}, "Fog"},
+		{Weather{VisibilityMiles: kilometers(0.63)}, "Haze"},
+		{Weather{VisibilityMiles: kilometers(6.2)}, "Moderate"},
+		{Weather{VisibilityMiles: kilometers(6.22)}, "Clear"},
+		{Weather{VisibilityKilometers: kilometers(0.5), VisibilityMiles: kilometers(10)}, "Fog"},
+	} {
+		if category, ok := test.weather.VisibilityCategory(); !ok || category != test.expected {
+			t.Errorf("Visibility category does not match expected value! We were expecting %s but got %s", test.expected, category)
+		}
+	}
+	if _, ok := (&Weather{}).VisibilityCategory(); ok {
+		t.Error("Expected no visibility category without visibility")
+	}
+}
+
+func TestWeatherPrecipitationMillimeters(t *testing.T) {
+	value := func(value float64) *float64 { return &value }
+	for _, test := range []struct {
+		name     string
+		weather  Weather
+		expected float64
+	}{
+		{"millimeters and inches agreeing", Weather{PrecipitationTodayMetric: value(2.6), PrecipitationTodayInches: value(0.1)}, 2.6},
+		{"centimeters and inches", Weather{PrecipitationTodayMetric: value(2.54), PrecipitationTodayInches: value(1)}, 25.4},
+		{"inches only", Weather{PrecipitationTodayInches: value(0.5)}, 12.7},
+		{"metric only", Weather{PrecipitationTodayMetric: value(3)}, 3},
+	} {
+		millimeters, ok := test.weather.PrecipitationMillimeters()
+		if !ok || math.Abs(millimeters-test.expected) > 1e-9 {
+			t.Errorf("Precipitation for %s does not match expected value! We were expecting %f but got %f", test.name, test.expected, millimeters)
+		}
+	}
+	if _, ok := (&Weather{}).PrecipitationMillimeters(); ok {
+		t.Error("Expected no precipitation without either field")
+	}
+}
+
+func TestWeatherUVCategory(t *testing.T) {
+	index := func(value float64) *float64 { return &value }
+	for _, test := range []struct {
+		uv       float64
+		expected string
+	}{
+		{0, "Low"},
+		{2.9, "Low"},
+		{3, "Moderate"},
+		{5.9, "Moderate"},
+		{6, "High"},
+		{7.9, "High"},
+		{8, "Very High"},
+		{10.9, "Very High"},
+		{11, "Extreme"},
+		{14, "Extreme"},
+	} {
+		weather := Weather{UVIndex: index(test.uv)}
+		if category, ok := weather.UVCategory(); !ok || category != test.expected {
+			t.Errorf("UV category for %v does not match expected value! We were expecting %s but got %s", test.uv, test.expected, category)
+		}
+	}
+	if _, ok := (&Weather{}).UVCategory(); ok {
+		t.Error("Expected no UV category without a UV index")
+	}
+}
+
+func TestGetReportsForDates(t *testing.T) {
+	backend, err := NewFilesystemBackend("./testData")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dates := []time.Time{
+		time.Date(2015, time.October, 23, 0, 0, 0, 0, time.UTC),
+		time.Date(2015, time.October, 24, 0, 0, 0, 0, time.UTC),
+		time.Date(2014, time.January, 15, 0, 0, 0, 0, time.UTC),
+	}
+	files, errs := GetReportsForDates(context.Background(), backend, dates, 2)
+	if len(files) != 3 || len(errs) != 3 {
+		t.Fatalf("Expected results parallel to the 3 dates but got %d files and %d errors", len(files), len(errs))
+	}
+	if errs[0] != nil || errs[2] != nil || files[0].Name != "2015-10-23-reporter-export.json" || files[2].Name != "2014-01-15-reporter-export.json" {
+		t.Errorf("Expected the existing reports to be fetched but got %v", errs)
+	}
+	if !errors.Is(errs[1], ErrReportNotFound) || files[1].Name != "" {
+		t.Errorf("Expected ErrReportNotFound for the missing date but got %v", errs[1])
+	}
+}
+
+func TestSnapshotWeatherLocationDistance(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	snapshot := day.Snapshots[0]
+	meters, ok := snapshot.WeatherLocationDistance()
+	if !ok || math.Abs(meters-439.86) > 0.1 {
+		t.Errorf("Weather location distance does not match expected value! We were expecting about 439.86 but got %f", meters)
+	}
+	snapshot.Weather.Latitude = nil
+	if _, ok := snapshot.WeatherLocationDistance(); ok {
+		t.Error("Expected no weather location distance without a weather latitude")
+	}
+}
+
+func TestDayAverageHorizontalAccuracy(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	invalid, precise := -1.0, 5.0
+	day.Snapshots[0].Location.HorizontalAccuracy = &invalid
+	day.Snapshots[1].Location.HorizontalAccuracy = &precise
+	if meters, ok := day.AverageHorizontalAccuracy(); !ok || meters != 45 {
+		t.Errorf("Average horizontal accuracy does not match expected value! We were expecting 45 but got %f", meters)
+	}
+	if _, ok := (&Day{Snapshots: []Snapshot{{}}}).AverageHorizontalAccuracy(); ok {
+		t.Error("Expected no average horizontal accuracy without located snapshots")
+	}
+}
+
+func TestDecoderFillWeatherUnits(t *testing.T) {
+	fileJSON := []byte(`{"snapshots":[{"weather":{"tempC":20,"windMPH":10,"visibilityKM":16.1,"pressureMb":1016,"precipTodayIn":0.1,"tempF":70}}]}`)
+	raw, err := DecodeJSONString(string(fileJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw.Snapshots[0].Weather.WindKilometersPerHour != nil {
+		t.Error("Expected weather units to not be filled by default")
+	}
+	day, err := NewDecoder(DecodeOptions{FillWeatherUnits: true}).Decode(fileJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weather := day.Snapshots[0].Weather
+	if *weather.TemperatureFarenheit != 70 {
+		t.Errorf("Expected the recorded Farenheit temperature to be kept but got %f", *weather.TemperatureFarenheit)
+	}
+	for name, test := range map[string]struct {
+		value    *float64
+		expected float64
+	}{
+		"windKPH":           {weather.WindKilometersPerHour, 16.09344},
+		"visibilityMi":      {weather.VisibilityMiles, 10.004},
+		"pressureIn":        {weather.PressureInches, 30.002},
+		"precipTodayMetric": {weather.PrecipitationTodayMetric, 2.54},
+	} {
+		if test.value == nil || math.Abs(*test.value-test.expected) > 0.001 {
+			t.Errorf("Filled %s does not match expected value! We were expecting %f but got %v", name, test.expected, test.value)
+		}
+	}
+	if weather.FeelsLikeCelsius != nil || weather.FeelsLikeFarenheit != nil {
+		t.Error("Expected pairs missing both units to be left empty")
+	}
+}
+
+func TestSnapshotHash(t *testing.T) {
+	for _, filePath := range []string{"./testData/2014-01-15-reporter-export.json", "./testData/2015-10-23-reporter-export.json"} {
+		day := loadTestFile(t, filePath)
+		// Re-encode the day using the other schema version and decode it again
+		otherVersion := 3 - day.SchemaVersion
+		encoded, err := marshalForSchemaVersion(day, otherVersion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reencoded, err := DecodeJSONString(string(encoded))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, snapshot := range day.Snapshots {
+			if hash, reencodedHash := snapshot.Hash(), reencoded.Snapshots[i].Hash(); hash != reencodedHash {
+				t.Errorf("Expected snapshot %d of %s to hash the same as schema version %d but got %s and %s", i, filePath, otherVersion, hash, reencodedHash)
+			}
+		}
+	}
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	snapshot := day.Snapshots[0]
+	hash := snapshot.Hash()
+	if len(hash) != 64 {
+		t.Errorf("Expected a SHA-256 hex digest but got %s", hash)
+	}
+	sync := 1
+	snapshot.Sync = &sync
+	if snapshot.Hash() != hash {
+		t.Error("Expected the Sync debug field to not change the hash")
+	}
+	steps := 1
+	snapshot.Steps = &steps
+	if snapshot.Hash() == hash {
+		t.Error("Expected a change in steps to change the hash")
+	}
+}
+
+func TestDayTimeSeries(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	times, values, err := day.TimeSeries("steps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []float64{278, 0, 1093, 648}
+	if !reflect.DeepEqual(values, expected) || len(times) != len(values) {
+		t.Errorf("Steps time series does not match expected value! We were expecting %v but got %v", expected, values)
+	}
+	for i := 1; i < len(times); i++ {
+		if times[i].Before(times[i-1]) {
+			t.Error("Expected the time series to be in time order")
+		}
+	}
+	day.Snapshots[1].Weather = nil
+	if times, values, _ := day.TimeSeries("tempC"); len(times) != 3 || len(values) != 3 {
+		t.Errorf("Expected the snapshot without weather to be skipped but got %d values", len(values))
+	}
+	if _, _, err := day.TimeSeries("humidity"); err == nil || !strings.Contains(err.Error(), "audioAvg, audioPeak, battery") {
+		t.Errorf("Expected an error listing the valid metrics but got %v", err)
+	}
+	v1 := loadTestFile(t, "./testData/2014-01-15-reporter-export.json")
+	if _, values, _ := v1.TimeSeries("steps"); len(values) != 0 {
+		t.Errorf("Expected schema version 1 snapshots without steps to be skipped but got %v", values)
+	}
+}
+
+// countingGeocoder is a Geocoder that records when it was called
+type countingGeocoder struct {
+	mutex sync.Mutex
+	calls []time.Time
+}
+
+func (g *countingGeocoder) Reverse(lat, long float64) (*Placemark, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.calls = append(g.calls, time.Now())
+	return &Placemark{Name: "Somewhere"}, nil
+}
+
+func TestRateLimitedGeocoder(t *testing.T) {
+	counting := &countingGeocoder{}
+	geocoder := RateLimitedGeocoder(counting, 50)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if placemark, err := geocoder.Reverse(37.8, -122.2); err != nil || placemark.Name != "Somewhere" {
+				t.Errorf("Expected the wrapped geocoder's placemark but got %v (%v)", placemark, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(counting.calls) != 5 {
+		t.Fatalf("Expected 5 geocoder calls but got %d", len(counting.calls))
+	}
+	// 5 calls at 50 per second need at least 4 intervals of 20ms between the first and last
+	if elapsed := counting.calls[4].Sub(counting.calls[0]); elapsed < 75*time.Millisecond {
+		t.Errorf("Expected the calls to be spread over at least 80ms but they took %s", elapsed)
+	}
+	if RateLimitedGeocoder(counting, 0) != Geocoder(counting) {
+		t.Error("Expected a rate of 0 to return the geocoder unchanged")
+	}
+}
+
+func TestCompareCatalogs(t *testing.T) {
+	a, err := NewFSBackend(os.DirFS("."), "testData")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewFSBackend(fstest.MapFS{
+		"2015-10-23-reporter-export.json":                            &fstest.MapFile{Data: []byte("{}")},
+		"2016-02-01-reporter-export.json":                            &fstest.MapFile{Data: []byte("{}")},
+		"2016-02-01-reporter-export (Robbie's conflicted copy).json": &fstest.MapFile{Data: []byte("{}")},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	onlyA, onlyB, err := CompareCatalogs(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedA := []time.Time{time.Date(2014, time.January, 15, 0, 0, 0, 0, time.UTC)}
+	expectedB := []time.Time{time.Date(2016, time.February, 1, 0, 0, 0, 0, time.UTC)}
+	if !reflect.DeepEqual(onlyA, expectedA) {
+		t.Errorf("Dates only in a do not match expected value! We were expecting %v but got %v", expectedA, onlyA)
+	}
+	if !reflect.DeepEqual(onlyB, expectedB) {
+		t.Errorf("Dates only in b do not match expected value! We were expecting %v but got %v", expectedB, onlyB)
+	}
+	if onlyA, onlyB, err := CompareCatalogs(a, a); err != nil || len(onlyA) != 0 || len(onlyB) != 0 {
+		t.Errorf("Expected a backend to match itself but got %v, %v (%v)", onlyA, onlyB, err)
+	}
+}
+
+func TestDayNumericAnswers(t *testing.T) {
+	day := loadTestFile(t, "./testData/2014-01-15-reporter-export.json")
+	expected := []float64{2, 0, 0, 0, 1}
+	if answers := day.NumericAnswers("COFFEES"); !reflect.DeepEqual(answers, expected) {
+		t.Errorf("Numeric answers do not match expected value! We were expecting %v but got %v", expected, answers)
+	}
+	if answers := day.NumericAnswers("mood"); len(answers) != 0 {
+		t.Errorf("Expected no numeric answers for mood but got %v", answers)
+	}
+}
+
+func TestDecoderKeepRaw(t *testing.T) {
+	raw, err := ioutil.ReadFile("./testData/2015-10-23-reporter-export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day, err := NewDecoder(DecodeOptions{KeepRaw: true}).Decode(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := day.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encoded, raw) {
+		t.Error("Expected JSON to return the original bytes verbatim")
+	}
+	day.Raw = nil
+	if encoded, err = day.JSON(); err != nil || bytes.Equal(encoded, raw) {
+		t.Errorf("Expected JSON to re-marshal the day once Raw is cleared (%v)", err)
+	}
+	if day, err = DecodeJSONString(string(raw)); err != nil || day.Raw != nil {
+		t.Errorf("Expected Raw to be left empty by default (%v)", err)
+	}
+}
+
+func TestDayConnectionBreakdown(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	expected := map[string]time.Duration{
+		"Wi-Fi":    12*time.Hour + 18*time.Minute + 26*time.Second,
+		"Cellular": 2*time.Hour + 36*time.Minute + 4*time.Second,
+	}
+	if breakdown := day.ConnectionBreakdown(); !reflect.DeepEqual(breakdown, expected) {
+		t.Errorf("Connection breakdown does not match expected value! We were expecting %v but got %v", expected, breakdown)
+	}
+	// The Wi-Fi snapshot before it covers its interval instead
+	day.Snapshots[1].Connection = nil
+	if breakdown := day.ConnectionBreakdown(); !reflect.DeepEqual(breakdown, expected) {
+		t.Errorf("Expected the snapshot without a connection to be skipped but got %v", breakdown)
+	}
+}
+
+func TestDayIntervals(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	// Reverse the snapshots to make sure they are sorted first
+	for i, j := 0, len(day.Snapshots)-1; i < j; i, j = i+1, j-1 {
+		day.Snapshots[i], day.Snapshots[j] = day.Snapshots[j], day.Snapshots[i]
+	}
+	min, max, median, ok := day.Intervals()
+	expectedMin := 2*time.Hour + 36*time.Minute + 4*time.Second
+	expectedMax := 9*time.Hour + 41*time.Minute + 17*time.Second
+	expectedMedian := 2*time.Hour + 37*time.Minute + 9*time.Second
+	if !ok || min != expectedMin || max != expectedMax || median != expectedMedian {
+		t.Errorf("Intervals do not match expected value! We were expecting %s, %s, %s but got %s, %s, %s (%t)", expectedMin, expectedMax, expectedMedian, min, max, median, ok)
+	}
+	day.Snapshots = day.Snapshots[:1]
+	if _, _, _, ok := day.Intervals(); ok {
+		t.Error("Expected no intervals for a single snapshot")
+	}
+}
+
+// recordingLogger is a Logger that keeps every message
+type recordingLogger struct {
+	debug, errors []string
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.debug = append(l.debug, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {
+	l.errors = append(l.errors, fmt.Sprintf(format, args...))
+}
+
+func TestBackendLogger(t *testing.T) {
+	backend, err := NewFSBackend(fstest.MapFS{
+		"2015-10-23-reporter-export.json": &fstest.MapFile{Data: []byte("{}")},
+		"2015-13-45-reporter-export.json": &fstest.MapFile{Data: []byte("{}")},
+		"notes.txt":                       &fstest.MapFile{Data: []byte("notes")},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Listing without a Logger must not panic
+	if files, err := backend.ListReports(); err != nil || len(files) != 1 {
+		t.Fatalf("Expected the report with an invalid date to be skipped but got %v (%v)", files, err)
+	}
+	logger := &recordingLogger{}
+	backend.Logger = logger
+	if _, err := backend.GetLatestReport(); err != nil {
+		t.Fatal(err)
+	}
+	if len(logger.errors) != 1 || !strings.Contains(logger.errors[0], "2015-13-45-reporter-export.json") {
+		t.Errorf("Expected an error about the invalid date but got %v", logger.errors)
+	}
+	if len(logger.debug) != 2 || !strings.Contains(logger.debug[0], "notes.txt") || !strings.Contains(logger.debug[1], "(2 bytes)") {
+		t.Errorf("Expected debug messages about the skipped file and the read but got %v", logger.debug)
+	}
+}
+
+func TestDecodeBOMAndTrailingData(t *testing.T) {
+	fileJSON, err := ioutil.ReadFile("./testData/bom-prefixed.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(fileJSON, []byte("\xef\xbb\xbf")) || !bytes.HasSuffix(fileJSON, []byte("\x00")) {
+		t.Fatal("Expected the fixture to start with a byte order mark and end with a NUL")
+	}
+	day, err := DecodeJSONString(string(fileJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(day.Snapshots) != 1 || day.Snapshots[0].Steps == nil || *day.Snapshots[0].Steps != 120 {
+		t.Errorf("Expected the snapshot in the fixture but got %s", day)
+	}
+	if days, err := DecodeDays(append(append([]byte("\xef\xbb\xbf["), fileJSON[3:len(fileJSON)-1]...), ']')); err != nil || len(days) != 1 {
+		t.Errorf("Expected an array of days with a byte order mark to be decoded but got %d days (%v)", len(days), err)
+	}
+	fileJSON, err = ioutil.ReadFile("./testData/trailing-brace.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = DecodeJSONString(string(fileJSON))
+	if !errors.Is(err, ErrTrailingData) {
+		t.Fatalf("Expected ErrTrailingData but got %v", err)
+	}
+	if expected := fmt.Sprintf("at byte %d", bytes.LastIndexByte(fileJSON, '}')); !strings.Contains(err.Error(), expected) {
+		t.Errorf("Expected the error to contain %q but got %q", expected, err)
+	}
+}
+
+func TestDayTemperatureExtremes(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	hottest, coldest, ok := day.TemperatureExtremes()
+	if !ok || hottest != &day.Snapshots[3] || coldest != &day.Snapshots[0] {
+		t.Fatalf("Expected the last snapshot to be the hottest and the first to be the coldest but got %v, %v (%t)", hottest, coldest, ok)
+	}
+	if temperature, _ := hottest.TemperatureCelsius(); temperature != 23.2 {
+		t.Errorf("Hottest temperature does not match expected value! We were expecting 23.2 but got %v", temperature)
+	}
+	for i := range day.Snapshots {
+		day.Snapshots[i].Weather = nil
+	}
+	if _, _, ok := day.TemperatureExtremes(); ok {
+		t.Error("Expected no temperature extremes without weather")
+	}
+}
+
+func TestDayGeoJSONTrack(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	track, err := day.GeoJSONTrack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var collection struct {
+		Type     string
+		Features []struct {
+			Geometry struct {
+				Type        string
+				Coordinates [][2]float64
+			}
+			Properties struct {
+				CoordTimes []string
+			}
+		}
+	}
+	if err := json.Unmarshal(track, &collection); err != nil {
+		t.Fatal(err)
+	}
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 1 || collection.Features[0].Geometry.Type != "LineString" {
+		t.Fatalf("Expected a FeatureCollection with one LineString but got %s", track)
+	}
+	feature := collection.Features[0]
+	if len(feature.Geometry.Coordinates) != 4 || len(feature.Properties.CoordTimes) != 4 {
+		t.Fatalf("Expected 4 points with times but got %s", track)
+	}
+	lat, long, _ := day.Snapshots[0].Coordinates()
+	if feature.Geometry.Coordinates[0] != [2]float64{long, lat} {
+		t.Errorf("First coordinate does not match expected value! We were expecting [%v %v] but got %v", long, lat, feature.Geometry.Coordinates[0])
+	}
+	if feature.Properties.CoordTimes[0] != "2015-10-23T00:10:30-07:00" {
+		t.Errorf("First coordinate time does not match expected value! We were expecting 2015-10-23T00:10:30-07:00 but got %s", feature.Properties.CoordTimes[0])
+	}
+	day.Snapshots = day.Snapshots[:1]
+	if track, err := day.GeoJSONTrack(); err != nil || string(track) != `{"type":"FeatureCollection","features":[]}` {
+		t.Errorf("Expected an empty FeatureCollection for a single point but got %s (%v)", track, err)
+	}
+}
+
+func TestOpenReport(t *testing.T) {
+	contents, err := ioutil.ReadFile("./testData/2015-10-23-reporter-export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filesystem, err := NewFilesystemBackend("./testData")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsBackend, err := NewFSBackend(os.DirFS("."), "testData")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backends := map[string]struct {
+		backend Backend
+		path    string
+	}{
+		"filesystem": {filesystem, "./testData/2015-10-23-reporter-export.json"},
+		"fs":         {fsBackend, "testData/2015-10-23-reporter-export.json"},
+		// Hiding the OpenReport method exercises the GetReportForPath fallback
+		"fallback": {struct{ Backend }{filesystem}, "./testData/2015-10-23-reporter-export.json"},
+	}
+	for name, test := range backends {
+		reader, err := OpenReport(context.Background(), test.backend, test.path)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		streamed, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil || !bytes.Equal(streamed, contents) {
+			t.Errorf("%s: Expected the streamed report to match the file (%v)", name, err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := OpenReport(ctx, filesystem, "./testData/2015-10-23-reporter-export.json"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled but got %v", err)
+	}
+}
+
+func TestCompareWeeks(t *testing.T) {
+	lastWeek := Week{
+		Start: time.Date(2014, time.January, 13, 0, 0, 0, 0, time.UTC),
+		Days:  []Day{loadTestFile(t, "./testData/2014-01-15-reporter-export.json")},
+	}
+	thisWeek := Week{
+		Start: time.Date(2015, time.October, 19, 0, 0, 0, 0, time.UTC),
+		Days:  []Day{loadTestFile(t, "./testData/2015-10-23-reporter-export.json")},
+	}
+	lastAverage, err := lastWeek.Average("tempC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	thisAverage, err := thisWeek.Average("tempC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thisAverage != (16.6+17.4+19.2+23.2)/4 {
+		t.Errorf("Average temperature does not match expected value! We were expecting %v but got %v", (16.6+17.4+19.2+23.2)/4, thisAverage)
+	}
+	delta, err := CompareWeeks(lastWeek, thisWeek, "tempC")
+	if err != nil || delta != thisAverage-lastAverage {
+		t.Errorf("Delta does not match expected value! We were expecting %v but got %v (%v)", thisAverage-lastAverage, delta, err)
+	}
+	if _, err := CompareWeeks(lastWeek, thisWeek, "mood"); err == nil || !strings.Contains(err.Error(), "valid metrics are") {
+		t.Errorf("Expected an error listing the valid metrics but got %v", err)
+	}
+	if _, err := CompareWeeks(lastWeek, Week{Start: thisWeek.Start}, "tempC"); !errors.Is(err, ErrNoMetricData) {
+		t.Errorf("Expected ErrNoMetricData for a week without data but got %v", err)
+	}
+	if _, err := lastWeek.Average("steps"); !errors.Is(err, ErrNoMetricData) {
+		t.Errorf("Expected ErrNoMetricData for a schema version 1 week without steps but got %v", err)
+	}
+	if _, err := CompareWeeks(lastWeek, thisWeek, "steps"); !errors.Is(err, ErrNoMetricData) {
+		t.Errorf("Expected ErrNoMetricData comparing a schema version 1 week without steps but got %v", err)
+	}
+}
+
+func TestDayChargingPeriods(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	// The fixture only rises once, between the first two reports, which isn't enough for a period
+	if periods := day.ChargingPeriods(); len(periods) != 0 {
+		t.Errorf("Expected a single rise to not be a charging period but got %v", periods)
+	}
+	// A slow charge with a noisy report in the middle is a single period, whether the battery is a fraction or a percentage
+	start, _ := day.Snapshots[0].EffectiveTime()
+	end, _ := day.Snapshots[3].EffectiveTime()
+	expected := []ChargingPeriod{{start, end}}
+	for _, levels := range [][]float64{{0.2, 0.205, 0.215, 0.5}, {20, 20.5, 21.5, 50}} {
+		for i, battery := range levels {
+			day.Snapshots[i].Battery = &battery
+		}
+		if periods := day.ChargingPeriods(); !reflect.DeepEqual(periods, expected) {
+			t.Errorf("Charging periods for %v do not match expected value! We were expecting %v but got %v", levels, expected, periods)
+		}
+	}
+	// Percentages that only move by noise are not charging
+	for i, battery := range []float64{50, 50.5, 51, 51.5} {
+		day.Snapshots[i].Battery = &battery
+	}
+	if periods := day.ChargingPeriods(); len(periods) != 0 {
+		t.Errorf("Expected percentage noise to not be a charging period but got %v", periods)
+	}
+	// A single rise between drains is not a period
+	for i, battery := range []float64{0.5, 0.4, 0.6, 0.3} {
+		day.Snapshots[i].Battery = &battery
+	}
+	if periods := day.ChargingPeriods(); len(periods) != 0 {
+		t.Errorf("Expected a single rise between drains to not be a charging period but got %v", periods)
+	}
+	for i, battery := range []float64{0.5, 0.4, 0.405, 0.3} {
+		day.Snapshots[i].Battery = &battery
+	}
+	if periods := day.ChargingPeriods(); len(periods) != 0 {
+		t.Errorf("Expected no charging periods while draining but got %v", periods)
+	}
+}
+
+func TestDayUsageProxy(t *testing.T) {
+	start := time.Date(2015, time.October, 23, 8, 0, 0, 0, time.UTC)
+	snapshot := func(hours float64, battery float64, connection int) Snapshot {
+		snapshot := Snapshot{Date: &DateTime{Time: start.Add(time.Duration(hours * float64(time.Hour)))}}
+		if battery >= 0 {
+			snapshot.Battery = &battery
+		}
+		if connection >= 0 {
+			snapshot.Connection = &ConnectionType{Type: connection}
+		}
+		return snapshot
+	}
+	for _, test := range []struct {
+		name      string
+		snapshots []Snapshot
+		expected  float64
+	}{
+		{"no snapshots", nil, 0},
+		{"one snapshot without fields", []Snapshot{{}}, 1.0 / 24},
+		{"snapshots without times", []Snapshot{{Battery: new(float64)}, {Battery: new(float64)}}, 2.0 / 24},
+		{"heavy cellular use", []Snapshot{snapshot(0, 0.9, 0), snapshot(1, 0.6, 0)}, 0.5 + 0.25*2/24 + 0.25},
+		{"light WiFi use", []Snapshot{snapshot(2, 0.45, 1), snapshot(0, 0.5, 1)}, 0.5*(0.025/0.15) + 0.25*2/24},
+		{"charging while offline", []Snapshot{snapshot(0, 0.5, 2), snapshot(1, 0.8, 2)}, 0.25 * 2 / 24 / 0.75},
+		{"missing battery and connection", []Snapshot{snapshot(0, -1, -1), snapshot(1, -1, -1)}, 2.0 / 24},
+	} {
+		day := Day{Snapshots: test.snapshots}
+		if score := day.UsageProxy(); math.Abs(score-test.expected) > 1e-9 {
+			t.Errorf("Usage proxy for %s does not match expected value! We were expecting %f but got %f", test.name, test.expected, score)
+		}
+	}
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	if score := day.UsageProxy(); score <= 0 || score > 1 {
+		t.Errorf("Expected the usage proxy of the fixture to be between 0 and 1 but got %f", score)
+	}
+}
+
+func TestDaySnapshotsBySection(t *testing.T) {
+	day := Day{Snapshots: []Snapshot{
+		{ID: "1", SectionIdentifier: "2-2015-10-23"},
+		{ID: "2"},
+		{ID: "3", SectionIdentifier: "1-2015-10-23"},
+		{ID: "4", SectionIdentifier: "2-2015-10-23"},
+		{ID: "5"},
+	}}
+	var got [][]string
+	for _, section := range day.SnapshotsBySection() {
+		ids := []string{section.Identifier}
+		for _, snapshot := range section.Snapshots {
+			ids = append(ids, snapshot.ID)
+		}
+		got = append(got, ids)
+	}
+	expected := [][]string{{"2-2015-10-23", "1", "4"}, {"", "2", "5"}, {"1-2015-10-23", "3"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Sections do not match expected value! We were expecting %v but got %v", expected, got)
+	}
+	if sections := (&Day{}).SnapshotsBySection(); len(sections) != 0 {
+		t.Errorf("Expected no sections for a day without snapshots but got %v", sections)
+	}
+}
+
+func TestDayAnsweredOptionsByQuestion(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	day.Snapshots = append(day.Snapshots, Snapshot{Responses: []*Response{
+		nil,
+		{QuestionPrompt: "Are you working?", AnsweredOptions: []string{"No", "Maybe"}},
+		{QuestionPrompt: "Where are you?"},
+	}})
+	expected := map[string][]string{
+		"Did you have lunch?":     {"No"},
+		"Did you have dinner?":    {"Yes"},
+		"Did you have breakfast?": {"Yes"},
+		"Are you working?":        {"No", "Yes", "Maybe"},
+	}
+	if options := day.AnsweredOptionsByQuestion(); !reflect.DeepEqual(options, expected) {
+		t.Errorf("Answered options do not match expected value! We were expecting %v but got %v", expected, options)
+	}
+	if options := (&Day{}).AnsweredOptionsByQuestion(); len(options) != 0 {
+		t.Errorf("Expected no answered options for a day without snapshots but got %v", options)
+	}
+}
+
+func TestDayQuestionsOfType(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	expected := []string{"Are you working?", "Did you have breakfast?", "Did you have lunch?", "Did you have dinner?"}
+	var prompts []string
+	for _, question := range day.QuestionsOfType(QuestionTypeYesNo) {
+		prompts = append(prompts, question.Prompt)
+	}
+	if !reflect.DeepEqual(prompts, expected) {
+		t.Errorf("Yes/No questions do not match expected value! We were expecting %v but got %v", expected, prompts)
+	}
+	if questions := day.QuestionsOfType(QuestionTypeLocation); len(questions) != 1 || questions[0].Prompt != "Where are you?" {
+		t.Errorf("Expected the location question but got %v", questions)
+	}
+	if questions := day.QuestionsOfType(99); len(questions) != 0 {
+		t.Errorf("Expected no questions of an unknown type but got %v", questions)
+	}
+}
+
+func TestRegionFormats(t *testing.T) {
+	fileJSON, err := ioutil.ReadFile("./testData/region-formats.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day, err := DecodeJSONString(string(fileJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Region{
+		{37.812086, -122.264681, 70.86, "<+37.81208600,-122.26468100> radius 70.86", ""},
+		{37.81110915, -122.266444, 35.58, "CLCircularRegion (identifier:'<+37.81110915,-122.26644400> radius 35.58', center:<+37.81110915,-122.26644400>, radius:35.58)", "<+37.81110915,-122.26644400> radius 35.58"},
+	}
+	for i, snapshot := range day.Snapshots {
+		if region := *snapshot.Location.Placemark.Region; region != expected[i] {
+			t.Errorf("Region %d does not match expected value! We were expecting %+v but got %+v", i, expected[i], region)
+		}
+	}
+	var region Region
+	if err := json.Unmarshal([]byte(`"CLCircularRegion (identifier:'Home' center:-33.865, 151.2094 radius:100)"`), &region); err != nil {
+		t.Fatal(err)
+	}
+	if region.RegionIdentifier != "Home" || region.Latitude != -33.865 || region.Longitude != 151.2094 || region.Radius != 100 {
+		t.Errorf("Expected the CLCircularRegion without commas to be parsed but got %+v", region)
+	}
+	for _, unrecognized := range []string{"somewhere", "<+1.2.3,4.5> radius 6"} {
+		encodedRegion, _ := json.Marshal(unrecognized)
+		if err := json.Unmarshal(encodedRegion, &region); err != nil {
+			t.Errorf("Expected no error for the unrecognized region %q but got %s", unrecognized, err)
+		}
+		if expected := (Region{Identifier: unrecognized}); region != expected {
+			t.Errorf("Unrecognized region does not match expected value! We were expecting %+v but got %+v", expected, region)
+		}
+	}
+	encoded, err := day.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reparsed, err := DecodeJSONString(string(encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if region := *reparsed.Snapshots[1].Location.Placemark.Region; region != expected[1] {
+		t.Errorf("Expected the original region to be written back but got %+v", region)
+	}
+}
+
+func TestDaySimplifiedPath(t *testing.T) {
+	day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	full := day.SimplifiedPath(0)
+	if len(full) != 4 {
+		t.Fatalf("Expected every point without a tolerance but got %v", full)
+	}
+	lat, long, _ := day.Snapshots[0].Coordinates()
+	if full[0] != [2]float64{long, lat} {
+		t.Errorf("First point does not match expected value! We were expecting [%v %v] but got %v", long, lat, full[0])
+	}
+	if simplified := day.SimplifiedPath(100000); len(simplified) != 2 || simplified[0] != full[0] || simplified[1] != full[3] {
+		t.Errorf("Expected only the first and last points with a large tolerance but got %v", simplified)
+	}
+	// Points along a straight line are redundant, but a detour is kept
+	day.Snapshots = nil
+	for i, point := range [][2]float64{{-122.27, 37.8}, {-122.26, 37.8}, {-122.25, 37.8}, {-122.24, 37.81}, {-122.23, 37.8}} {
+		date := DateTime{Time: time.Date(2015, time.October, 23, i, 0, 0, 0, time.UTC)}
+		latitude, longitude := point[1], point[0]
+		day.Snapshots = append(day.Snapshots, Snapshot{Date: &date, Location: &Location{Latitude: &latitude, Longitude: &longitude}})
+	}
+	expected := [][2]float64{{-122.27, 37.8}, {-122.25, 37.8}, {-122.24, 37.81}, {-122.23, 37.8}}
+	if simplified := day.SimplifiedPath(10); !reflect.DeepEqual(simplified, expected) {
+		t.Errorf("Simplified path does not match expected value! We were expecting %v but got %v", expected, simplified)
+	}
+}
+
+func TestDecoderInferDateFromFilename(t *testing.T) {
+	file := File{
+		Name:             "2014-01-15-reporter-export.json",
+		TimeFromFilename: time.Date(2014, time.January, 15, 0, 0, 0, 0, time.UTC),
+		Contents:         `{"snapshots":[{"battery":0.5},{"battery":0.4,"date":"2014-01-15T13:48:40-0700"}]}`,
+	}
+	day, err := DecodeFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if day.Snapshots[0].Date != nil {
+		t.Error("Expected the missing date to be left nil by default")
+	}
+	losAngeles, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	day, err = NewDecoder(DecodeOptions{InferDateFromFilename: true, Location: losAngeles}).DecodeFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := time.Date(2014, time.January, 15, 0, 0, 0, 0, losAngeles)
+	if day.Snapshots[0].Date == nil || !day.Snapshots[0].Date.Equal(expected) {
+		t.Errorf("Inferred date does not match expected value! We were expecting %s but got %v", expected, day.Snapshots[0].Date)
+	}
+	if !day.Snapshots[1].Date.Equal(time.Date(2014, time.January, 15, 20, 48, 40, 0, time.UTC)) {
+		t.Errorf("Expected the existing date to be kept but got %s", day.Snapshots[1].Date)
+	}
+}
+
+func TestDetectSchemaVersion(t *testing.T) {
+	tests := map[string]struct {
+		json     string
+		version  int
+		expected error
+	}{
+		"v1":        {`{"snapshots":[{"date":406482520.294946}]}`, 1, nil},
+		"v2":        {`{"snapshots":[{"date":"2015-10-23T00:10:30-0700"}]}`, 2, nil},
+		"ambiguous": {`{"snapshots":[{"battery":0.9}]}`, DefaultSchemaVersion, ErrAmbiguousSchema},
+	}
+	for name, test := range tests {
+		version, err := DetectSchemaVersion([]byte(test.json))
+		if version != test.version || err != test.expected {
+			t.Errorf("%s: Expected schema version %d (%v) but got %d (%v)", name, test.version, test.expected, version, err)
+		}
+	}
+	// Decoding an ambiguous report after a version 1 report uses the default instead of the previous report's version
+	if _, err := DecodeJSONString(tests["v1"].json); err != nil {
+		t.Fatal(err)
+	}
+	day, err := DecodeJSONString(tests["ambiguous"].json)
+	if err != nil || day.SchemaVersion != DefaultSchemaVersion {
+		t.Errorf("Expected schema version %d but got %d (%v)", DefaultSchemaVersion, day.SchemaVersion, err)
+	}
+	if _, err := DetectSchemaVersion([]byte("{")); err == nil || errors.Is(err, ErrAmbiguousSchema) {
+		t.Errorf("Expected a decoding error for invalid JSON but got %v", err)
+	}
+}
+
+func TestPhotoDisplayStrings(t *testing.T) {
+	day := loadTestFile(t, "./testData/2014-01-15-reporter-export.json")
+	photo := day.AllPhotos()[0]
+	if aperture, ok := photo.ApertureString(); !ok || aperture != "f/2.4" {
+		t.Errorf("Aperture does not match expected value! We were expecting f/2.4 but got %s", aperture)
+	}
+	if focalLength, ok := photo.FocalLengthString(); !ok || focalLength != "33mm (35mm equiv)" {
+		t.Errorf("Focal length does not match expected value! We were expecting 33mm (35mm equiv) but got %s", focalLength)
+	}
+	photo.FocalLengthIn35mm = nil
+	if focalLength, ok := photo.FocalLengthString(); !ok || focalLength != "4.12mm" {
+		t.Errorf("Focal length does not match expected value! We were expecting 4.12mm but got %s", focalLength)
+	}
+	var empty Photo
+	if _, ok := empty.ApertureString(); ok {
+		t.Error("Expected no aperture for a photo without an FNumber")
+	}
+	if _, ok := empty.FocalLengthString(); ok {
+		t.Error("Expected no focal length for a photo without one")
+	}
+}
+
+func TestConcurrentMarshalAndDecode(t *testing.T) {
+	v1JSON, err := ioutil.ReadFile("./testData/2014-01-15-reporter-export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2Day := loadTestFile(t, "./testData/2015-10-23-reporter-export.json")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			day, err := DecodeJSONString(string(v1JSON))
+			if err != nil || day.SchemaVersion != 1 {
+				t.Errorf("Expected schema version 1 but got %d (%v)", day.SchemaVersion, err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := v2Day.JSON(); err != nil {
+				t.Error(err)
+			}
+			v2Day.Snapshots[0].Hash()
+			v2Day.Diff(v2Day)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConcurrentDecodeDetectsEachVersion(t *testing.T) {
+	versions := map[string]int{
+		"./testData/2014-01-15-reporter-export.json": 1,
+		"./testData/2015-10-23-reporter-export.json": 2,
+	}
+	decoder := NewDecoder(DecodeOptions{})
+	var wg sync.WaitGroup
+	for path, version := range versions {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(contents []byte, version int) {
+				defer wg.Done()
+				if day, err := decoder.Decode(contents); err != nil || day.SchemaVersion != version {
+					t.Errorf("Schema version does not match expected value! We were expecting %d but got %d (%v)", version, day.SchemaVersion, err)
+				}
+			}(contents, version)
+		}
+	}
+	wg.Wait()
+	// The last timestamp or token in the document decides the version, as it did when they set SchemaVersion while decoding
+	if version := schemaVersionOf([]byte(`{"snapshots":[{"date":406482520.294946},{"date":"2015-10-23T00:10:30-0700"}]}`), reflect.TypeOf(Day{})); version != 2 {
+		t.Errorf("Schema version does not match expected value! We were expecting 2 but got %d", version)
+	}
+}
+
+func TestDecoderSkipFieldsDoNotLeak(t *testing.T) {
+	contents, err := ioutil.ReadFile("./testData/2015-10-23-reporter-export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	skimming := NewDecoder(DecodeOptions{SkipWeather: true})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if day, err := skimming.Decode(contents); err != nil || day.Snapshots[0].Weather != nil {
+				t.Errorf("Expected the weather to be skipped (%v)", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if day, err := DecodeJSONString(string(contents)); err != nil || day.Snapshots[0].Weather == nil {
+				t.Errorf("Expected the default decoder to keep the weather (%v)", err)
+			}
+		}()
+	}
+	wg.Wait()
 }
@@ -1,8 +1,16 @@
 package reporter
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -15,39 +23,79 @@ var AppleEpochTime = time.Unix(978307200, 0)
 // ISO8601 is the standard ISO 8601 timestamp format for Go
 const ISO8601 = "2006-01-02T15:04:05-0700"
 
+// iso8601Layouts are the timestamp layouts accepted when decoding schema v2 timestamps, in the order they are tried.
+// Fractional seconds are accepted by all of them.
+var iso8601Layouts = []string{ISO8601, "2006-01-02T15:04:05Z0700", time.RFC3339Nano}
+
 // DateTime is a special wrapper around time.Time due to complexities around schema differences.
 // In version 1 of the schema, timestamps were expressed in seconds since Apple epoch.
 // In version 2 of the schema, the app started using standard ISO 8601 timestamps
-type DateTime struct{ time.Time }
+type DateTime struct {
+	time.Time
+	raw string // The timestamp as originally read from JSON, kept so an unchanged timestamp can be written back out exactly
+}
 
 func (d *DateTime) String() string {
 	if SchemaVersion == 1 {
-		return strconv.FormatFloat(d.Sub(AppleEpochTime).Seconds(), 'f', -1, 64)
+		return d.appleEpochSeconds()
+	}
+	return d.iso8601()
+}
+
+// appleEpochSeconds returns the number of seconds since Apple epoch.
+// If the time hasn't changed since it was decoded, the original JSON number is returned so re-encoding is byte for byte stable.
+func (d *DateTime) appleEpochSeconds() string {
+	if d.raw != "" {
+		if inputDuration, err := time.ParseDuration(d.raw + "s"); err == nil && AppleEpochTime.Add(inputDuration).Equal(d.Time) {
+			return d.raw
+		}
+	}
+	return strconv.FormatFloat(d.Sub(AppleEpochTime).Seconds(), 'f', -1, 64)
+}
+
+// iso8601 returns the ISO 8601 timestamp.
+// If the time hasn't changed since it was decoded, the original string is returned so re-encoding keeps its layout.
+func (d *DateTime) iso8601() string {
+	if d.raw != "" {
+		if dateTime, err := parseISO8601(d.raw); err == nil && dateTime.Equal(d.Time) {
+			return d.raw
+		}
 	}
 	return d.Format(ISO8601)
 }
 
+// parseISO8601 parses a timestamp using each of the iso8601Layouts until one succeeds
+func parseISO8601(value string) (dateTime time.Time, err error) {
+	for _, layout := range iso8601Layouts {
+		if dateTime, err = time.Parse(layout, value); err == nil {
+			return
+		}
+	}
+	return
+}
+
 // MarshalJSON is needed to return either a date string that is ISO 8601 formatted (schema v2) or the number of seconds since Apple epoch (schema v1)
 func (d *DateTime) MarshalJSON() ([]byte, error) {
 	if SchemaVersion == 1 {
-		return json.Marshal(d.Sub(AppleEpochTime).Seconds())
+		return []byte(d.appleEpochSeconds()), nil
 	}
-	return json.Marshal(d.Format(ISO8601))
+	return json.Marshal(d.iso8601())
 }
 
 // UnmarshalJSON handles deserialization of a timestamp.
 // This custom unmarshaling is needed because the input property may be an ISO 8601 timestamp
-// or number of seconds since Apple Epoch (January 1st, 2001 00:00:00 UTC)
+// or number of seconds since Apple Epoch (January 1st, 2001 00:00:00 UTC).
+// ISO 8601 timestamps may use a Z or colon separated offset and have fractional seconds.
 func (d *DateTime) UnmarshalJSON(data []byte) (err error) {
 	var dateTime time.Time
 	dateString, rawJSON := "", json.RawMessage{}
 	if err = json.Unmarshal(data, &dateString); err == nil {
-		dateTime, err = time.Parse(ISO8601, dateString)
+		dateTime, err = parseISO8601(dateString)
 		if err != nil {
 			return
 		}
-		SchemaVersion = 2
 		d.Time = dateTime
+		d.raw = dateString
 		return
 	}
 	if err = json.Unmarshal(data, &rawJSON); err == nil {
@@ -58,8 +106,8 @@ func (d *DateTime) UnmarshalJSON(data []byte) (err error) {
 		}
 		// BUG(robbiet480): For now, this returns older style timestamps in local time according to computer setting
 		dateTime = AppleEpochTime.Add(inputDuration).Local()
-		SchemaVersion = 1
 		d.Time = dateTime
+		d.raw = string(rawJSON)
 		return
 	}
 	return
@@ -108,6 +156,11 @@ func (c *ConnectionType) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// IsOnline returns true if the device was connected via cellular network or WiFi
+func (c *ConnectionType) IsOnline() bool {
+	return c.Type == 0 || c.Type == 1
+}
+
 // A ReportImpetus struct indicates how the report was triggered.
 // The value for the impetus attribute cooresponds to the following events:
 //
@@ -155,6 +208,12 @@ func (r *ReportImpetus) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// IsAutomatic returns true if the report was triggered by the app (a notification, or the app going to sleep or waking up)
+// rather than by tapping the report button
+func (r *ReportImpetus) IsAutomatic() bool {
+	return r.Impetus == 2 || r.Impetus == 3 || r.Impetus == 4
+}
+
 // Photo struct contains the EXIF metadata of a single photo.
 // Additionally, the photo struct contains a link to the photo asset within iOS.
 // Currently, this information is unused witin the Reporter application and is not of much use outside the iOS system.
@@ -192,10 +251,106 @@ type Photo struct {
 	WhiteBalance      *int      `json:"whiteBalance,omitempty"`
 }
 
+// TakenAt returns the time the photo was taken according to its EXIF DateTime,
+// which may differ from the Date of the snapshot it was filed with.
+// ok will be false if the photo has no DateTime.
+func (p *Photo) TakenAt() (time.Time, bool) {
+	if p.DateTime == nil {
+		return time.Time{}, false
+	}
+	return p.DateTime.Time, true
+}
+
+// ApertureString returns the FNumber the way photographers write it, i.e. f/2.2. ok will be false if the photo has no FNumber.
+func (p *Photo) ApertureString() (string, bool) {
+	if p.FNumber == nil || *p.FNumber <= 0 {
+		return "", false
+	}
+	return "f/" + strconv.FormatFloat(roundPlus(*p.FNumber, 1), 'f', -1, 64), true
+}
+
+// FocalLengthString returns the focal length the way photographers compare lenses, i.e. 29mm (35mm equiv).
+// The 35mm equivalent from FocalLengthIn35mm is preferred, since the actual FocalLength of a phone lens, i.e. 4.15mm, means little on its own.
+// ok will be false if the photo has neither.
+func (p *Photo) FocalLengthString() (string, bool) {
+	if p.FocalLengthIn35mm != nil && *p.FocalLengthIn35mm > 0 {
+		return fmt.Sprintf("%dmm (35mm equiv)", *p.FocalLengthIn35mm), true
+	}
+	if p.FocalLength != nil && *p.FocalLength > 0 {
+		return strconv.FormatFloat(roundPlus(*p.FocalLength, 2), 'f', -1, 64) + "mm", true
+	}
+	return "", false
+}
+
 // PhotoSet is a struct with a single array of photos written to the snapshot if the user has taken photos between reports.
+// Some newer exports write an array of photo sets instead, which are merged into one PhotoSet using the ID of the first set.
 type PhotoSet struct {
 	ID     string  `json:"uniqueIdentifier,omitempty"`
 	Photos []Photo `json:"photos,omitempty"`
+
+	sets []photoSetShape // The photo sets that were merged, if the JSON was an array
+}
+
+type photoSet PhotoSet
+
+// photoSetShape records the ID and number of photos of one of the photo sets in an array
+type photoSetShape struct {
+	id    string
+	count int
+}
+
+// MarshalJSON writes the photo set in the shape it was read in, splitting the photos back into the original sets if it was an array.
+// Photos added since decoding are written to the last set.
+func (p *PhotoSet) MarshalJSON() ([]byte, error) {
+	if p.sets == nil {
+		return json.Marshal(photoSet(*p))
+	}
+	sets := make([]photoSet, 0, len(p.sets))
+	remaining := p.Photos
+	for i, shape := range p.sets {
+		count := shape.count
+		if i == len(p.sets)-1 || count > len(remaining) {
+			count = len(remaining)
+		}
+		sets = append(sets, photoSet{ID: shape.id, Photos: remaining[:count]})
+		remaining = remaining[count:]
+	}
+	return json.Marshal(sets)
+}
+
+// UnmarshalJSON decodes either a single photo set object or an array of them.
+// Numeric photo fields that were written as strings are decoded as numbers, as they are for Snapshot.
+func (p *PhotoSet) UnmarshalJSON(b []byte) error {
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '[' {
+		var sets []PhotoSet
+		if err := json.Unmarshal(trimmed, &sets); err != nil {
+			return err
+		}
+		merged := PhotoSet{sets: []photoSetShape{}}
+		for i, set := range sets {
+			if i == 0 {
+				merged.ID = set.ID
+			}
+			merged.Photos = append(merged.Photos, set.Photos...)
+			merged.sets = append(merged.sets, photoSetShape{set.ID, len(set.Photos)})
+		}
+		*p = merged
+		return nil
+	}
+	var decoded photoSet
+	err := json.Unmarshal(b, &decoded)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Value == "string" {
+		if b, err = unquoteNumbers(b, reflect.TypeOf(decoded)); err == nil {
+			decoded = photoSet{}
+			err = json.Unmarshal(b, &decoded)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	*p = PhotoSet(decoded)
+	return nil
 }
 
 // Altitude is a struct containing detailed altitude information at the time of the report.
@@ -228,30 +383,46 @@ type Audio struct {
 // (x + 65) * 2 where x is the raw value Apple gives us, again, -160 dB to 0 dB.
 // You can still use the raw values from Apple (in JSON) and apply any correction or calibration as they see to be appropriate.
 func (a *Audio) PositiveAverageDb(rounded bool) float64 {
-	value := (float64(*a.Average) + float64(65)) * 2
 	if rounded {
-		return roundPlus(value, 2)
+		return a.PositiveAverageDbPlaces(2)
 	}
-	return value
+	return (float64(*a.Average) + float64(65)) * 2
+}
+
+// PositiveAverageDbPlaces returns PositiveAverageDb rounded to the given number of decimal places, i.e. 0 for whole decibels.
+func (a *Audio) PositiveAverageDbPlaces(places int) float64 {
+	return roundPlus(a.PositiveAverageDb(false), places)
 }
 
 // PositivePeakDb does the same calculation the app does to show a positive Db peak value instead of the standard negative Db.
 func (a *Audio) PositivePeakDb(rounded bool) float64 {
-	value := (float64(*a.Peak) + float64(65)) * 2
 	if rounded {
-		return roundPlus(value, 2)
+		return a.PositivePeakDbPlaces(2)
 	}
-	return value
+	return (float64(*a.Peak) + float64(65)) * 2
+}
+
+// PositivePeakDbPlaces returns PositivePeakDb rounded to the given number of decimal places, i.e. 0 for whole decibels.
+func (a *Audio) PositivePeakDbPlaces(places int) float64 {
+	return roundPlus(a.PositivePeakDb(false), places)
 }
 
 // A Region is a struct containing a parsed CLPlacemark Region
 type Region struct {
-	Latitude   float64 `json:"-"`
-	Longitude  float64 `json:"-"`
-	Radius     float64 `json:"-"`
-	Identifier string  `json:"-"`
+	Latitude         float64 `json:"-"`
+	Longitude        float64 `json:"-"`
+	Radius           float64 `json:"-"`
+	Identifier       string  `json:"-"` // The region exactly as the app wrote it
+	RegionIdentifier string  `json:"-"` // The identifier inside the CLCircularRegion format, empty for the older format
 }
 
+// regionPattern matches the older format of CLPlacemark.region, i.e. <+37.81208600,-122.26468100> radius 70.86
+var regionPattern = regexp.MustCompile(`^\s*<\s*([+-]?[0-9.]+)\s*,\s*([+-]?[0-9.]+)\s*>\s*radius\s+([0-9.]+)`)
+
+// circularRegionPattern matches the CLCircularRegion format of CLPlacemark.region written by newer versions of iOS, i.e.
+// CLCircularRegion (identifier:'<+37.81208600,-122.26468100> radius 70.86', center:<+37.81208600,-122.26468100>, radius:70.86)
+var circularRegionPattern = regexp.MustCompile(`^\s*CLCircularRegion\s*\(\s*identifier:'(.*)',?\s*center:\s*<?\s*([+-]?[0-9.]+)\s*,\s*([+-]?[0-9.]+)\s*>?,?\s*radius:\s*([0-9.]+)\s*\)`)
+
 func (r *Region) String() string { return r.Identifier }
 
 // MarshalJSON is needed to return only the Region identifier
@@ -260,31 +431,39 @@ func (r *Region) MarshalJSON() ([]byte, error) {
 }
 
 // UnmarshalJSON provides custom JSON unmarshaling for region.
-// It splits up the string format of CLPlacemark.region into a Golang usable format
+// It splits up the string format of CLPlacemark.region into a Golang usable format.
+// Both the older <latitude,longitude> radius format and the CLCircularRegion format of newer versions of iOS are supported.
+// A region in any other format is kept in Identifier, so it is written back unchanged, and the rest of the fields are left zero.
 func (r *Region) UnmarshalJSON(b []byte) (err error) {
 	var placemark string
-	if err = json.Unmarshal(b, &placemark); err == nil {
-		replacer := strings.NewReplacer("<", "", ">", "", ",", " ", "+", "")
-		cleanedString := replacer.Replace(placemark)
-		splitFields := strings.Fields(cleanedString)
-		lat, err := strconv.ParseFloat(splitFields[0], 64)
-		if err != nil {
-			return err
-		}
-		lon, err := strconv.ParseFloat(splitFields[1], 64)
-		if err != nil {
-			return err
-		}
-		rad, err := strconv.ParseFloat(splitFields[3], 64)
-		if err != nil {
-			return err
-		}
-		r.Identifier = placemark
-		r.Latitude = lat
-		r.Longitude = lon
-		r.Radius = rad
+	if err = json.Unmarshal(b, &placemark); err != nil {
+		return err
+	}
+	*r = Region{Identifier: placemark}
+	var identifier, lat, lon, rad string
+	if matches := circularRegionPattern.FindStringSubmatch(placemark); matches != nil {
+		identifier, lat, lon, rad = matches[1], matches[2], matches[3], matches[4]
+	} else if matches := regionPattern.FindStringSubmatch(placemark); matches != nil {
+		lat, lon, rad = matches[1], matches[2], matches[3]
+	} else {
+		return nil
 	}
-	return err
+	latitude, latErr := strconv.ParseFloat(lat, 64)
+	longitude, lonErr := strconv.ParseFloat(lon, 64)
+	radius, radErr := strconv.ParseFloat(rad, 64)
+	if latErr != nil || lonErr != nil || radErr != nil {
+		return nil
+	}
+	r.RegionIdentifier = identifier
+	r.Latitude = latitude
+	r.Longitude = longitude
+	r.Radius = radius
+	return nil
+}
+
+// Contains returns true if the latitude/longitude pair is within Radius meters of the center of the region
+func (r *Region) Contains(lat, long float64) bool {
+	return haversineMeters(r.Latitude, r.Longitude, lat, long) <= r.Radius
 }
 
 // Placemark struct is the result of reverse geocoding the latitude and longitude deribed from iOS's location services.
@@ -303,6 +482,29 @@ type Placemark struct {
 	Name                  string  `json:"name,omitempty"`
 }
 
+// FormattedAddress assembles the placemark into a single line address in the form
+//
+//	SubThoroughfare Thoroughfare, Locality, AdministrativeArea PostalCode, Country
+//
+// Empty components are left out. If every component is empty, an empty string is returned.
+func (p *Placemark) FormattedAddress() string {
+	joinNonEmpty := func(separator string, values ...string) string {
+		var nonEmpty []string
+		for _, value := range values {
+			if value = strings.TrimSpace(value); value != "" {
+				nonEmpty = append(nonEmpty, value)
+			}
+		}
+		return strings.Join(nonEmpty, separator)
+	}
+	return joinNonEmpty(", ",
+		joinNonEmpty(" ", p.SubThoroughfare, p.Thoroughfare),
+		p.Locality,
+		joinNonEmpty(" ", p.AdministrativeArea, p.PostalCode),
+		p.Country,
+	)
+}
+
 // A Location struct is essentially a CoreLocation CLLocation (https://developer.apple.com/library/ios/documentation/CoreLocation/Reference/CLLocation_Class/CLLocation/CLLocation.html#//apple_ref/doc/uid/TP40007126) object, with a CLPlacemark embedded (https://developer.apple.com/library/ios/documentation/CoreLocation/Reference/CLPlacemark_class/Reference/Reference.html#//apple_ref/doc/uid/TP40009574).
 // Refer to the linked documentation for each class for details on their properties.
 type Location struct {
@@ -318,6 +520,18 @@ type Location struct {
 	HorizontalAccuracy *float64   `json:"horizontalAccuracy,omitempty"`
 }
 
+// metersToFeet is the number of feet in a meter
+const metersToFeet = 3.28084
+
+// AltitudeFeet returns the Altitude, which is recorded in meters, converted to feet.
+// ok will be false if the altitude wasn't recorded.
+func (l *Location) AltitudeFeet() (float64, bool) {
+	if l.Altitude == nil {
+		return 0, false
+	}
+	return *l.Altitude * metersToFeet, true
+}
+
 // The Weather struct is perhaps the most self-explanitory of the data captured.
 // struct keys are descriptive, detailing the metric and the units used.
 type Weather struct {
@@ -347,6 +561,98 @@ type Weather struct {
 	WindMilesPerHour          *float64 `json:"windMPH,omitempty"`
 }
 
+// UVCategory returns the World Health Organization exposure category for the UVIndex:
+// Low (below 3), Moderate (3 to 5), High (6 and 7), Very High (8 to 10) and Extreme (11 and above).
+// ok will be false if the UV index wasn't recorded.
+func (w *Weather) UVCategory() (category string, ok bool) {
+	if w.UVIndex == nil {
+		return "", false
+	}
+	switch uv := *w.UVIndex; {
+	case uv < 3:
+		return "Low", true
+	case uv < 6:
+		return "Moderate", true
+	case uv < 8:
+		return "High", true
+	case uv < 11:
+		return "Very High", true
+	}
+	return "Extreme", true
+}
+
+// VisibilityCategory returns a label for how far could be seen, using the meteorological visibility bands:
+// Fog (below 1km), Haze (1km to below 5km), Moderate (5km to below 10km) and Clear (10km and above).
+// VisibilityKilometers is used, falling back to VisibilityMiles converted at 1.609344km per mile.
+// ok will be false if visibility wasn't recorded.
+func (w *Weather) VisibilityCategory() (category string, ok bool) {
+	var kilometers float64
+	switch {
+	case w.VisibilityKilometers != nil:
+		kilometers = *w.VisibilityKilometers
+	case w.VisibilityMiles != nil:
+		kilometers = *w.VisibilityMiles * 1.609344
+	default:
+		return "", false
+	}
+	switch {
+	case kilometers < 1:
+		return "Fog", true
+	case kilometers < 5:
+		return "Haze", true
+	case kilometers < 10:
+		return "Moderate", true
+	}
+	return "Clear", true
+}
+
+// FillDerivedUnits fills in whichever of each metric/imperial pair of fields is missing by converting the other,
+// so temperatures, feels like temperatures, wind and gust speeds, visibility, pressure and precipitation are all available in both units.
+// Fields that are already set are never changed.
+func (w *Weather) FillDerivedUnits() {
+	fill := func(metric, imperial **float64, toImperial func(float64) float64, toMetric func(float64) float64) {
+		switch {
+		case *metric != nil && *imperial == nil:
+			value := toImperial(**metric)
+			*imperial = &value
+		case *imperial != nil && *metric == nil:
+			value := toMetric(**imperial)
+			*metric = &value
+		}
+	}
+	celsiusToFarenheit := func(c float64) float64 { return c*9/5 + 32 }
+	farenheitToCelsius := func(f float64) float64 { return (f - 32) * 5 / 9 }
+	kilometersToMiles := func(km float64) float64 { return km / 1.609344 }
+	milesToKilometers := func(mi float64) float64 { return mi * 1.609344 }
+	fill(&w.TemperatureCelsius, &w.TemperatureFarenheit, celsiusToFarenheit, farenheitToCelsius)
+	fill(&w.FeelsLikeCelsius, &w.FeelsLikeFarenheit, celsiusToFarenheit, farenheitToCelsius)
+	fill(&w.WindKilometersPerHour, &w.WindMilesPerHour, kilometersToMiles, milesToKilometers)
+	fill(&w.WindGustKilometersPerHour, &w.WindGustMilesPerHour, kilometersToMiles, milesToKilometers)
+	fill(&w.VisibilityKilometers, &w.VisibilityMiles, kilometersToMiles, milesToKilometers)
+	fill(&w.PressureMillibars, &w.PressureInches, func(mb float64) float64 { return mb / 33.8639 }, func(in float64) float64 { return in * 33.8639 })
+	fill(&w.PrecipitationTodayMetric, &w.PrecipitationTodayInches, func(mm float64) float64 { return mm / 25.4 }, func(in float64) float64 { return in * 25.4 })
+}
+
+// PrecipitationMillimeters returns the precipitation for the day so far in millimeters.
+// PrecipitationTodayMetric is usually in millimeters but has been seen in centimeters, so when both fields are present
+// and the metric value doesn't agree with PrecipitationTodayInches converted at 25.4mm per inch (within 0.5mm or 10%),
+// the converted inches are returned instead. ok will be false if neither field is present.
+func (w *Weather) PrecipitationMillimeters() (millimeters float64, ok bool) {
+	switch {
+	case w.PrecipitationTodayInches != nil && w.PrecipitationTodayMetric != nil:
+		fromInches := *w.PrecipitationTodayInches * 25.4
+		if math.Abs(*w.PrecipitationTodayMetric-fromInches) <= math.Max(0.5, fromInches*0.1) {
+			return *w.PrecipitationTodayMetric, true
+		}
+		return fromInches, true
+	case w.PrecipitationTodayInches != nil:
+		return *w.PrecipitationTodayInches * 25.4, true
+	case w.PrecipitationTodayMetric != nil:
+		return *w.PrecipitationTodayMetric, true
+	}
+	return 0, false
+}
+
 // Token is an individual common repsonses, either words or phrases
 type Token struct {
 	ID   string `json:"uniqueIdentifier,omitempty"`
@@ -372,12 +678,10 @@ func (t *Token) UnmarshalJSON(b []byte) (err error) {
 	j, n := token{}, ""
 	if err = json.Unmarshal(b, &j); err == nil {
 		*t = Token(j)
-		SchemaVersion = 2
 		return
 	}
 	if err = json.Unmarshal(b, &n); err == nil {
 		t.Text = n
-		SchemaVersion = 1
 	}
 	return
 }
@@ -412,6 +716,41 @@ type Response struct {
 	TextResponse    string            `json:"textResponse,omitempty"`  // v1
 }
 
+// NormalizedOptions returns the AnsweredOptions with surrounding whitespace trimmed, empty options removed
+// and duplicates removed case-insensitively, keeping the casing of the first occurrence. The result is sorted case-insensitively.
+func (r *Response) NormalizedOptions() []string {
+	var options []string
+	seen := make(map[string]bool)
+	for _, option := range r.AnsweredOptions {
+		option = strings.TrimSpace(option)
+		key := strings.ToLower(option)
+		if option == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		options = append(options, option)
+	}
+	sort.Slice(options, func(i, j int) bool { return strings.ToLower(options[i]) < strings.ToLower(options[j]) })
+	return options
+}
+
+// PlaceText returns the best available label for the place given in a location response. In order of preference, it is
+// the text of the response, the name of the placemark of the response's location, or the FoursquareVenueID.
+// An empty string is returned if the response has no location response or none of them are set.
+func (r *Response) PlaceText() string {
+	locationResponse := r.Location
+	if locationResponse == nil {
+		return ""
+	}
+	if text := strings.TrimSpace(locationResponse.Text); text != "" {
+		return text
+	}
+	if location := locationResponse.Location; location != nil && location.Placemark != nil && location.Placemark.Name != "" {
+		return location.Placemark.Name
+	}
+	return locationResponse.FoursquareVenueID
+}
+
 // A Snapshot is single report for the day
 type Snapshot struct {
 	ID                string          `json:"uniqueIdentifier,omitempty"`  //
@@ -421,8 +760,8 @@ type Snapshot struct {
 	SectionIdentifier string          `json:"sectionIdentifier,omitempty"` // A convenience variable used by the application when displaying reports in a UITableView.
 	Audio             *Audio          `json:"audio,omitempty"`             //
 	Background        *int            `json:"background,omitempty"`        // A state variable indicating the report was captured in the background. We are not captuing reports in the background. Therefore, this attribute is not in use.
-	Date              *DateTime       `json:"date,omitempty"`              //
-	Day               *DateTime       `json:"day,omitempty"`               //
+	Date              *DateTime       `json:"date,omitempty"`              // The instant the report was filed. Use it to order snapshots.
+	Day               *DateTime       `json:"day,omitempty"`               // The calendar day the report belongs to (schema version 1 only). Use it to group snapshots by day.
 	Location          *Location       `json:"location,omitempty"`          //
 	PhotoSet          *PhotoSet       `json:"photoSet,omitempty"`          //
 	Weather           *Weather        `json:"weather,omitempty"`           //
@@ -432,4 +771,373 @@ type Snapshot struct {
 	Draft             *int            `json:"draft,omitempty"`             // A state variable indicating the report is being edited. If it is, it won't be saved. Therefore, this will always be 0.
 	DwellStatus       *int            `json:"dwellStatus,omitempty"`       // Debug variable. Not in use.
 	Sync              *int            `json:"sync,omitempty"`              // This is a state variable to ensure each report is saved to Dropbox. It will always be 0 because once it is 1 (or true) the app will not attempt to write it to Dropbox.
+
+	extra map[string]json.RawMessage
+}
+
+type snapshot Snapshot
+
+var snapshotFields = jsonFieldNames(reflect.TypeOf(Snapshot{}))
+
+// Extra returns any JSON fields found while decoding that are not otherwise mapped to the Snapshot struct.
+// They are written back out when the snapshot is marshaled so that fields added by newer versions of the app are not lost.
+func (s *Snapshot) Extra() map[string]json.RawMessage {
+	return s.extra
+}
+
+// Clone returns a deep copy of the snapshot so it can be modified without affecting the original
+func (s Snapshot) Clone() Snapshot {
+	var clone Snapshot
+	deepCopy(reflect.ValueOf(&clone).Elem(), reflect.ValueOf(s))
+	clone.extra = copyJSONFields(s.extra)
+	return clone
+}
+
+// MarshalJSON writes out the snapshot along with any unknown fields captured while decoding
+func (s Snapshot) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(snapshot(s))
+	if err != nil {
+		return nil, err
+	}
+	return appendJSONFields(b, s.extra)
+}
+
+// fieldSkips selects the heavy snapshot fields that are skipped while decoding
+type fieldSkips struct {
+	photos, weather, responses bool
+}
+
+// skippedJSON discards a JSON value without materializing it
+type skippedJSON struct{}
+
+func (*skippedJSON) UnmarshalJSON([]byte) error { return nil }
+
+// skippingTarget returns the value to unmarshal a snapshot into so that the fields selected by skips are discarded.
+// The returned struct shadows the skipped fields of the embedded snapshot, while the fields that aren't skipped point back into it.
+func skippingTarget(decoded *snapshot, skips fieldSkips) interface{} {
+	if skips == (fieldSkips{}) {
+		return decoded
+	}
+	target := &struct {
+		*snapshot
+		PhotoSet  interface{} `json:"photoSet"`
+		Weather   interface{} `json:"weather"`
+		Responses interface{} `json:"responses"`
+	}{decoded, &decoded.PhotoSet, &decoded.Weather, &decoded.Responses}
+	if skips.photos {
+		target.PhotoSet = &skippedJSON{}
+	}
+	if skips.weather {
+		target.Weather = &skippedJSON{}
+	}
+	if skips.responses {
+		target.Responses = &skippedJSON{}
+	}
+	return target
+}
+
+// UnmarshalJSON decodes the snapshot, capturing any fields not mapped to the Snapshot struct.
+// Numeric fields that were written as strings, i.e. "battery":"0.9", are decoded as numbers.
+func (s *Snapshot) UnmarshalJSON(b []byte) error {
+	return s.unmarshalJSON(b, fieldSkips{})
+}
+
+// unmarshalJSON is UnmarshalJSON, leaving the fields selected by skips nil
+func (s *Snapshot) unmarshalJSON(b []byte, skips fieldSkips) error {
+	var decoded snapshot
+	err := json.Unmarshal(b, skippingTarget(&decoded, skips))
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Value == "string" {
+		if b, err = unquoteNumbers(b, reflect.TypeOf(decoded)); err == nil {
+			decoded = snapshot{}
+			err = json.Unmarshal(b, skippingTarget(&decoded, skips))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	extra, err := unknownJSONFields(b, snapshotFields)
+	if err != nil {
+		return err
+	}
+	*s = Snapshot(decoded)
+	s.extra = extra
+	return nil
+}
+
+// Hash returns a SHA-256 hex digest of the contents of the snapshot, for detecting whether a snapshot changed.
+// The unused Background, DwellStatus and Sync fields are left out, and timestamps are compared to the second
+// regardless of their time zone, so the hash is the same no matter which schema version the snapshot was read from.
+func (s Snapshot) Hash() string {
+	canonical := s.Clone()
+	canonical.Background, canonical.DwellStatus, canonical.Sync = nil, nil, nil
+	truncateDateTimes(reflect.ValueOf(&canonical).Elem())
+	// Schema version 1 writes tokens as plain text, since their identifiers don't survive being written as version 1.
+	// Marshaling a snapshot can't fail, as every field is a plain value or has a MarshalJSON that doesn't return errors.
+	encoded, _ := marshalForSchemaVersion(canonical, 1)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// IsOnline returns true if the device was connected via cellular network or WiFi at the time of the report.
+// It returns false if the connection is unknown.
+func (s *Snapshot) IsOnline() bool {
+	return s.Connection != nil && s.Connection.IsOnline()
+}
+
+// EffectiveTime returns the time of the report, preferring Date and falling back to Day when Date is missing.
+// ok will be false if neither is set.
+func (s *Snapshot) EffectiveTime() (time.Time, bool) {
+	if s.Date != nil {
+		return s.Date.Time, true
+	}
+	if s.Day != nil {
+		return s.Day.Time, true
+	}
+	return time.Time{}, false
+}
+
+// BestAltitudeMeters returns the most reliable altitude recorded for the snapshot in meters along with the source it was read from.
+// Sources are tried in this order:
+//
+// "location": Location.Altitude, as long as the Location has a valid (non-negative) VerticalAccuracy
+//
+// "gpsAltitudeFromLocation": Altitude.GPSAltitudeFromLocation
+//
+// "gpsRawAltitude": Altitude.GPSRawAltitude
+//
+// ok will be false if none of the sources are available.
+func (s *Snapshot) BestAltitudeMeters() (meters float64, source string, ok bool) {
+	if location := s.Location; location != nil && location.Altitude != nil && location.VerticalAccuracy != nil && *location.VerticalAccuracy >= 0 {
+		return *location.Altitude, "location", true
+	}
+	if s.Altitude != nil {
+		if s.Altitude.GPSAltitudeFromLocation != nil {
+			return *s.Altitude.GPSAltitudeFromLocation, "gpsAltitudeFromLocation", true
+		}
+		if s.Altitude.GPSRawAltitude != nil {
+			return *s.Altitude.GPSRawAltitude, "gpsRawAltitude", true
+		}
+	}
+	return 0, "", false
+}
+
+// BatteryFraction returns the battery level at the time of the report as a fraction between 0 and 1.
+// Battery is documented as a fraction, but some exports store it as a percentage between 0 and 100 instead.
+// Values above 1 are assumed to be percentages and divided by 100, and the result is clamped to between 0 and 1.
+// ok will be false if the battery level wasn't recorded.
+func (s *Snapshot) BatteryFraction() (fraction float64, ok bool) {
+	if s.Battery == nil {
+		return 0, false
+	}
+	level := *s.Battery
+	if level > 1 {
+		level = level / 100
+	}
+	return math.Max(0, math.Min(1, level)), true
+}
+
+// BatteryPercent returns BatteryFraction as a percentage between 0 and 100.
+// ok will be false if the battery level wasn't recorded.
+func (s *Snapshot) BatteryPercent() (percent float64, ok bool) {
+	fraction, ok := s.BatteryFraction()
+	return fraction * 100, ok
+}
+
+// TemperatureCelsius returns the temperature in Celsius at the time of the report.
+// ok will be false if the snapshot has no weather or the temperature wasn't recorded.
+func (s *Snapshot) TemperatureCelsius() (float64, bool) {
+	if s.Weather == nil || s.Weather.TemperatureCelsius == nil {
+		return 0, false
+	}
+	return *s.Weather.TemperatureCelsius, true
+}
+
+// TemperatureFarenheit returns the temperature in Farenheit at the time of the report.
+// ok will be false if the snapshot has no weather or the temperature wasn't recorded.
+func (s *Snapshot) TemperatureFarenheit() (float64, bool) {
+	if s.Weather == nil || s.Weather.TemperatureFarenheit == nil {
+		return 0, false
+	}
+	return *s.Weather.TemperatureFarenheit, true
+}
+
+// Coordinates returns the latitude and longitude of the device at the time of the report.
+// ok will be false if the snapshot has no location or either coordinate is missing.
+func (s *Snapshot) Coordinates() (lat, long float64, ok bool) {
+	if s.Location == nil || s.Location.Latitude == nil || s.Location.Longitude == nil {
+		return 0, 0, false
+	}
+	return *s.Location.Latitude, *s.Location.Longitude, true
+}
+
+// WeatherLocationDistance returns the distance in meters between the coordinates the weather was fetched for and the Coordinates of the snapshot.
+// A large distance means the weather was stale or cached from somewhere else.
+// ok will be false if either the weather or the location is missing a latitude or longitude.
+func (s *Snapshot) WeatherLocationDistance() (meters float64, ok bool) {
+	lat, long, ok := s.Coordinates()
+	if !ok || s.Weather == nil || s.Weather.Latitude == nil || s.Weather.Longitude == nil {
+		return 0, false
+	}
+	return haversineMeters(lat, long, *s.Weather.Latitude, *s.Weather.Longitude), true
+}
+
+// IsDaylight returns true if the sun was above the horizon where and when the report was filed, meaning it was between sunrise and sunset.
+// It is calculated from the Coordinates and EffectiveTime without any network access, so it also works for polar day and night.
+// ok will be false if the snapshot has no coordinates or time.
+func (s *Snapshot) IsDaylight() (daylight bool, ok bool) {
+	lat, long, ok := s.Coordinates()
+	if !ok {
+		return false, false
+	}
+	reportTime, ok := s.EffectiveTime()
+	if !ok {
+		return false, false
+	}
+	return solarElevationDegrees(reportTime, lat, long) > sunriseElevationDegrees, true
+}
+
+// AudioAverageDb returns the raw average decibels recorded at the time of the report.
+// ok will be false if the snapshot has no audio or the average wasn't recorded.
+func (s *Snapshot) AudioAverageDb() (float64, bool) {
+	if s.Audio == nil || s.Audio.Average == nil {
+		return 0, false
+	}
+	return *s.Audio.Average, true
+}
+
+// AudioPeakDb returns the raw peak decibels recorded at the time of the report.
+// ok will be false if the snapshot has no audio or the peak wasn't recorded.
+func (s *Snapshot) AudioPeakDb() (float64, bool) {
+	if s.Audio == nil || s.Audio.Peak == nil {
+		return 0, false
+	}
+	return *s.Audio.Peak, true
+}
+
+// PressureMillibars returns the barometric pressure in millibars at the time of the report.
+// ok will be false if the snapshot has no weather or the pressure wasn't recorded.
+func (s *Snapshot) PressureMillibars() (float64, bool) {
+	if s.Weather == nil || s.Weather.PressureMillibars == nil {
+		return 0, false
+	}
+	return *s.Weather.PressureMillibars, true
+}
+
+// A WeatherProvider returns the historical weather for a latitude/longitude at a point in time.
+// It is used to backfill snapshots that were recorded without weather, such as while the device was offline.
+type WeatherProvider interface {
+	Historical(lat, lon float64, t time.Time) (*Weather, error)
+}
+
+// ErrNoWeather is returned by a WeatherProvider that has no weather for the location and time asked for
+var ErrNoWeather = errors.New("No weather available")
+
+// NopWeatherProvider is a WeatherProvider that never has weather, returning ErrNoWeather for every lookup.
+// It can stand in for a real provider when backfilling weather is optional.
+type NopWeatherProvider struct{}
+
+// Historical always returns ErrNoWeather
+func (NopWeatherProvider) Historical(lat, lon float64, t time.Time) (*Weather, error) {
+	return nil, ErrNoWeather
+}
+
+// FillWeather fetches the weather for the snapshot's location and time from p if the snapshot has no weather.
+// Snapshots that already have weather are left untouched. An error is returned if the snapshot has no coordinates or time.
+// Errors from p are returned as is and leave the snapshot without weather, so a provider without weather for the snapshot,
+// such as NopWeatherProvider, makes FillWeather return ErrNoWeather, which callers can check for with errors.Is to skip the snapshot.
+func (s *Snapshot) FillWeather(p WeatherProvider) error {
+	if s.Weather != nil {
+		return nil
+	}
+	lat, long, ok := s.Coordinates()
+	if !ok {
+		return errors.New("Snapshot has no coordinates to fetch weather for")
+	}
+	snapshotTime, ok := s.EffectiveTime()
+	if !ok {
+		return errors.New("Snapshot has no time to fetch weather for")
+	}
+	weather, err := p.Historical(lat, long, snapshotTime)
+	if err != nil {
+		return err
+	}
+	s.Weather = weather
+	return nil
+}
+
+// CalendarDay returns midnight of the calendar day the report belongs to.
+// Day is the calendar day the app filed the report under and is preferred, falling back to the day of Date for schema version 2,
+// which no longer records Day. The returned time is in the same location as the timestamp it was derived from.
+// ok will be false if neither is set.
+func (s *Snapshot) CalendarDay() (time.Time, bool) {
+	dateTime := s.Day
+	if dateTime == nil {
+		dateTime = s.Date
+	}
+	if dateTime == nil {
+		return time.Time{}, false
+	}
+	year, month, day := dateTime.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, dateTime.Location()), true
+}
+
+// fieldUnits maps the JSON path of each numeric snapshot field to its unit, as documented on the structs above
+var fieldUnits = map[string]string{
+	"steps":                            "steps",
+	"battery":                          "fraction",
+	"audio.avg":                        "dB",
+	"audio.peak":                       "dB",
+	"altitude.adjustedPressure":        "kPa",
+	"altitude.pressure":                "kPa",
+	"altitude.floorsAscended":          "floors",
+	"altitude.floorsDescended":         "floors",
+	"altitude.gpsAltitudeFromLocation": "m",
+	"altitude.gpsRawAltitude":          "m",
+	"location.speed":                   "m/s",
+	"location.course":                  "°",
+	"location.latitude":                "°",
+	"location.longitude":               "°",
+	"location.altitude":                "m",
+	"location.horizontalAccuracy":      "m",
+	"location.verticalAccuracy":        "m",
+	"photoSet.photos.exposureTime":     "s",
+	"photoSet.photos.focalLength":      "mm",
+	"photoSet.photos.latitude":         "°",
+	"photoSet.photos.longitude":        "°",
+	"photoSet.photos.pixelHeight":      "px",
+	"photoSet.photos.pixelWidth":       "px",
+	"weather.tempC":                    "°C",
+	"weather.tempF":                    "°F",
+	"weather.feelslikeC":               "°C",
+	"weather.feelslikeF":               "°F",
+	"weather.dewpointC":                "°C",
+	"weather.visibilityKM":             "km",
+	"weather.visibilityMi":             "mi",
+	"weather.precipTodayIn":            "in",
+	"weather.precipTodayMetric":        "mm",
+	"weather.pressureIn":               "inHg",
+	"weather.pressureMb":               "mb",
+	"weather.windKPH":                  "km/h",
+	"weather.windMPH":                  "mph",
+	"weather.windGustKPH":              "km/h",
+	"weather.windGustMPH":              "mph",
+	"weather.windDegrees":              "°",
+	"weather.latitude":                 "°",
+	"weather.longitude":                "°",
+	"weather.uv":                       "UV index",
+}
+
+// FieldUnits returns the unit of each numeric field of a snapshot, keyed by the dotted path of JSON keys to the field, i.e.
+//
+//	"weather.tempC": "°C"
+//
+// Array indexes are left out of the path, so every photo shares "photoSet.photos.focalLength".
+// The returned map is a copy and can be modified freely.
+func FieldUnits() map[string]string {
+	units := make(map[string]string, len(fieldUnits))
+	for field, unit := range fieldUnits {
+		units[field] = unit
+	}
+	return units
 }
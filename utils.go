@@ -1,17 +1,69 @@
 package reporter
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math"
 	"net/http"
+	"net/url"
+	"path"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
-// dateForFilename is a simple helper function to return a Time from a filename
-func dateForFilename(path string) (time.Time, error) {
-	return time.Parse("2006-01-02-reporter-export.json", filepath.Base(path))
+// conflictedCopyPattern matches the suffix Dropbox adds to the name of a conflicted copy,
+// i.e. 2015-10-23-reporter-export (conflicted copy 2015-10-24).json or 2015-10-23-reporter-export (Robbie's conflicted copy 2015-10-24).json
+var conflictedCopyPattern = regexp.MustCompile(` \([^)]*conflicted copy[^)]*\)`)
+
+// isReportFilename returns true if the base of the path is a Reporter export, including Dropbox conflicted copies
+func isReportFilename(filePath string) bool {
+	return strings.Contains(conflictedCopyPattern.ReplaceAllString(filepath.Base(filePath), ""), "-reporter-export.json")
+}
+
+// isConflictedCopy returns true if the base of the path is a Dropbox conflicted copy
+func isConflictedCopy(filePath string) bool {
+	return conflictedCopyPattern.MatchString(filepath.Base(filePath))
+}
+
+// latestReport returns the file with the latest TimeFromFilename.
+// When several files share that date, such as Dropbox conflicted copies, the most recently modified is returned.
+func latestReport(files []File) (File, bool) {
+	var latest File
+	found := false
+	for _, file := range files {
+		if !found || file.TimeFromFilename.After(latest.TimeFromFilename) ||
+			(file.TimeFromFilename.Equal(latest.TimeFromFilename) && file.ModifiedTime.After(latest.ModifiedTime)) {
+			latest = file
+			found = true
+		}
+	}
+	return latest, found
+}
+
+// dateForFilename is a simple helper function to return a Time from a filename.
+// The filename may be a full path or URL, in which case any query string or fragment is ignored.
+// The date of a Dropbox conflicted copy is the date of the report it conflicts with.
+func dateForFilename(filePath string) (time.Time, error) {
+	name := filepath.Base(filePath)
+	if strings.Contains(filePath, "://") {
+		if parsedURL, err := url.Parse(filePath); err == nil {
+			name = path.Base(parsedURL.Path)
+		}
+	} else if index := strings.IndexAny(filePath, "?#"); index != -1 {
+		name = filepath.Base(filePath[:index])
+	}
+	return time.Parse("2006-01-02-reporter-export.json", conflictedCopyPattern.ReplaceAllString(name, ""))
 }
 
 // googleTimezoneResponse is a struct to contain the response from Google with the timezone for the given latitude and longitude
@@ -23,13 +75,42 @@ type googleTimezoneResponse struct {
 	TimeZoneName string `json:"timeZoneName"`
 }
 
+// DefaultTimezoneTimeout is the timeout applied to timezone lookups when no HTTP client is provided
+const DefaultTimezoneTimeout = 10 * time.Second
+
+var defaultTimezoneClient = &http.Client{Timeout: DefaultTimezoneTimeout}
+
+// A TimezoneResolver returns the timezone identifier (i.e. America/Los_Angeles) for a latitude/longitude at the given unix timestamp
+type TimezoneResolver interface {
+	TimezoneForLocation(ctx context.Context, timestamp int64, lat, long float64) (string, error)
+}
+
+// GoogleTimezoneResolver looks up timezones using the Google Maps Time Zone API
+type GoogleTimezoneResolver struct {
+	Client *http.Client // The HTTP client used for lookups. If nil, a client with DefaultTimezoneTimeout is used.
+}
+
+// TimezoneForLocation returns the timezone identifier (i.e. America/Los_Angeles) for the given latitude/longitude at the given unix timestamp.
+// The lookup is cancelled if ctx is done before Google responds.
+func (g *GoogleTimezoneResolver) TimezoneForLocation(ctx context.Context, timestamp int64, lat, long float64) (string, error) {
+	client := g.Client
+	if client == nil {
+		client = defaultTimezoneClient
+	}
+	return getTimezoneForLocation(ctx, client, timestamp, lat, long)
+}
+
 // getTimezoneForLocation returns the timezone identifier (i.e. America/Los_Angeles) for the given latitude/longitude
-func getTimezoneForLocation(timestamp int64, lat, long float64) (string, error) {
+func getTimezoneForLocation(ctx context.Context, client *http.Client, timestamp int64, lat, long float64) (string, error) {
 	url := fmt.Sprintf("https://maps.googleapis.com/maps/api/timezone/json?location=%f,%f&timestamp=%d", lat, long, timestamp)
 
 	var gResp googleTimezoneResponse
 
-	request, err := http.Get(url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	request, err := client.Do(req.WithContext(ctx))
 	if err != nil {
 		return "", err
 	}
@@ -43,6 +124,111 @@ func getTimezoneForLocation(timestamp int64, lat, long float64) (string, error)
 	return gResp.TimeZoneID, nil
 }
 
+// earthRadiusMeters is the mean radius of the Earth used for distance calculations
+const earthRadiusMeters = 6371008.8
+
+// haversineMeters returns the great circle distance in meters between two latitude/longitude pairs
+func haversineMeters(lat1, long1, lat2, long2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	deltaLat := toRadians(lat2 - lat1)
+	deltaLong := toRadians(long2 - long1)
+	a := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(deltaLong/2)*math.Sin(deltaLong/2)
+	return 2 * earthRadiusMeters * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// sunriseElevationDegrees is the solar elevation at sunrise and sunset, accounting for atmospheric refraction and the radius of the sun
+const sunriseElevationDegrees = -0.833
+
+// solarElevationDegrees returns the approximate elevation of the sun above the horizon in degrees at time t for a latitude/longitude pair.
+// It uses the low precision solar coordinates from the Astronomical Almanac, which are accurate to about a minute of sunrise/sunset time.
+func solarElevationDegrees(t time.Time, lat, long float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	// Days since the J2000.0 epoch
+	n := float64(t.UnixNano())/float64(24*time.Hour) - 10957.5
+	meanLongitude := math.Mod(280.460+0.9856474*n, 360)
+	meanAnomaly := toRadians(math.Mod(357.528+0.9856003*n, 360))
+	eclipticLongitude := toRadians(meanLongitude + 1.915*math.Sin(meanAnomaly) + 0.020*math.Sin(2*meanAnomaly))
+	obliquity := toRadians(23.439 - 0.0000004*n)
+	rightAscension := math.Atan2(math.Cos(obliquity)*math.Sin(eclipticLongitude), math.Cos(eclipticLongitude))
+	declination := math.Asin(math.Sin(obliquity) * math.Sin(eclipticLongitude))
+	siderealDegrees := math.Mod(280.46061837+360.98564736629*n, 360) + long
+	hourAngle := toRadians(siderealDegrees) - rightAscension
+	latitude := toRadians(lat)
+	elevation := math.Asin(math.Sin(latitude)*math.Sin(declination) + math.Cos(latitude)*math.Cos(declination)*math.Cos(hourAngle))
+	return elevation * 180 / math.Pi
+}
+
+// DefaultMaxReportBytes is the largest report a backend reads when its MaxReportBytes isn't set
+const DefaultMaxReportBytes = 32 << 20
+
+// ErrReportTooLarge is returned by backends when a report is larger than their MaxReportBytes
+var ErrReportTooLarge = errors.New("Report is too large")
+
+// readReport reads all of r, failing with ErrReportTooLarge as soon as more than maxBytes have been read.
+// If maxBytes isn't greater than 0, DefaultMaxReportBytes is used.
+func readReport(r io.Reader, maxBytes int64) ([]byte, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxReportBytes
+	}
+	contents, err := ioutil.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(contents)) > maxBytes {
+		return nil, fmt.Errorf("%w, the limit is %d bytes", ErrReportTooLarge, maxBytes)
+	}
+	return contents, nil
+}
+
+// sortSnapshots sorts snapshots in place by their EffectiveTime. Snapshots without a time are moved to the end.
+// Snapshots reported in the same instant are ordered by uniqueIdentifier and then SectionIdentifier,
+// so the order is the same no matter how the snapshots were ordered before sorting.
+func sortSnapshots(snapshots []Snapshot) {
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		iTime, iOk := snapshots[i].EffectiveTime()
+		jTime, jOk := snapshots[j].EffectiveTime()
+		if iOk != jOk {
+			return iOk
+		}
+		if !iTime.Equal(jTime) {
+			return iTime.Before(jTime)
+		}
+		if snapshots[i].ID != snapshots[j].ID {
+			return snapshots[i].ID < snapshots[j].ID
+		}
+		return snapshots[i].SectionIdentifier < snapshots[j].SectionIdentifier
+	})
+}
+
+// sortFiles sorts files in place by the date in their filename, ordering files with the same date by path
+func sortFiles(files []File) {
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].TimeFromFilename.Equal(files[j].TimeFromFilename) {
+			return files[i].TimeFromFilename.Before(files[j].TimeFromFilename)
+		}
+		return files[i].Path < files[j].Path
+	})
+}
+
+// sortTimes sorts times in place in ascending order
+func sortTimes(times []time.Time) {
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+}
+
+// newUUID returns a random (version 4) UUID in the uppercase form the app uses for uniqueIdentifiers
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%X-%X-%X-%X-%X", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
 func round(f float64) float64 {
 	return math.Floor(f + .5)
 }
@@ -51,3 +237,501 @@ func roundPlus(f float64, places int) float64 {
 	shift := math.Pow(10, float64(places))
 	return round(f*shift) / shift
 }
+
+// filenameForDate is the inverse of dateForFilename, returning the export filename Reporter uses for the given day
+func filenameForDate(date time.Time) string {
+	return date.Format("2006-01-02-reporter-export.json")
+}
+
+// truncateToDay returns midnight UTC of the calendar day of date, matching the times parsed by dateForFilename
+func truncateToDay(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// marshalForSchemaVersion marshals v as the given schema version would, restoring the package SchemaVersion afterwards.
+// A version of 0 leaves the current SchemaVersion untouched. decodeMutex is held while marshaling,
+// so neither a concurrent decode nor another marshal can change SchemaVersion underneath it.
+func marshalForSchemaVersion(v interface{}, version int) ([]byte, error) {
+	decodeMutex.Lock()
+	defer decodeMutex.Unlock()
+	if version != 0 {
+		previousVersion := SchemaVersion
+		SchemaVersion = version
+		defer func() { SchemaVersion = previousVersion }()
+	}
+	return json.Marshal(v)
+}
+
+// diffJSON marshals a and b using the given schema version and describes every field that differs between them, prefixing each description.
+func diffJSON(prefix string, a, b interface{}, version int) ([]string, error) {
+	var values [2]interface{}
+	for i, v := range []interface{}{a, b} {
+		marshaled, err := marshalForSchemaVersion(v, version)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(marshaled, &values[i]); err != nil {
+			return nil, err
+		}
+	}
+	return diffJSONValues(prefix, "", values[0], values[1]), nil
+}
+
+// diffJSONValues recursively compares two decoded JSON values, describing differences using dotted field paths
+func diffJSONValues(prefix, fieldPath string, a, b interface{}) []string {
+	describe := func(v interface{}) string {
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+	name := fieldPath
+	if name == "" {
+		name = "value"
+	}
+	join := func(key string) string {
+		if fieldPath == "" {
+			return key
+		}
+		return fieldPath + "." + key
+	}
+	switch aValue := a.(type) {
+	case map[string]interface{}:
+		bValue, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(aValue)+len(bValue))
+		for key := range aValue {
+			keys = append(keys, key)
+		}
+		for key := range bValue {
+			if _, ok := aValue[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		var diffs []string
+		for _, key := range keys {
+			aField, aOk := aValue[key]
+			bField, bOk := bValue[key]
+			switch {
+			case !bOk:
+				diffs = append(diffs, fmt.Sprintf("%s%s removed", prefix, join(key)))
+			case !aOk:
+				diffs = append(diffs, fmt.Sprintf("%s%s added with %s", prefix, join(key), describe(bField)))
+			default:
+				diffs = append(diffs, diffJSONValues(prefix, join(key), aField, bField)...)
+			}
+		}
+		return diffs
+	case []interface{}:
+		bValue, ok := b.([]interface{})
+		if !ok || len(aValue) != len(bValue) {
+			break
+		}
+		var diffs []string
+		for i := range aValue {
+			diffs = append(diffs, diffJSONValues(prefix, join(strconv.Itoa(i)), aValue[i], bValue[i])...)
+		}
+		return diffs
+	}
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s%s changed from %s to %s", prefix, name, describe(a), describe(b))}
+}
+
+// jsonFieldNames returns the lowercased JSON keys that map to fields of the given struct type.
+// Keys are lowercased because encoding/json matches keys to fields case-insensitively.
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for name := range jsonFieldTypes(t) {
+		names[name] = true
+	}
+	return names
+}
+
+// jsonFieldTypes returns the types of the fields of the given struct type, keyed by their lowercased JSON keys.
+func jsonFieldTypes(t reflect.Type) map[string]reflect.Type {
+	types := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		types[strings.ToLower(name)] = field.Type
+	}
+	return types
+}
+
+// unknownSchemaFields returns the paths of the fields in the JSON data that aren't part of the schema of t, i.e. snapshots[0].weather.newField.
+// Every object is compared with the fields of the struct it decodes into, including objects nested in arrays.
+// An array that decodes into a struct, such as the array form of PhotoSet, has each of its elements compared with the struct.
+func unknownSchemaFields(data []byte, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+	var unknown []string
+	switch {
+	case data[0] == '[' && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Struct):
+		elementType := t
+		if t.Kind() != reflect.Struct {
+			elementType = t.Elem()
+		}
+		var elements []json.RawMessage
+		if err := json.Unmarshal(data, &elements); err != nil {
+			return nil
+		}
+		for i, element := range elements {
+			unknown = append(unknown, unknownSchemaFields(element, elementType, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case data[0] == '{' && t.Kind() == reflect.Struct:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil
+		}
+		known := jsonFieldTypes(t)
+		for key, value := range fields {
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			if fieldType, ok := known[strings.ToLower(key)]; ok {
+				unknown = append(unknown, unknownSchemaFields(value, fieldType, fieldPath)...)
+			} else {
+				unknown = append(unknown, fieldPath)
+			}
+		}
+	}
+	return unknown
+}
+
+// tokenType is the reflect.Type of Token
+var tokenType = reflect.TypeOf(Token{})
+
+// schemaVersionOf returns the schema version revealed by the timestamps and tokens in the JSON data for the type t,
+// walking the JSON by shape as unknownSchemaFields does. Timestamps are numbers and tokens are strings in version 1,
+// while version 2 uses ISO 8601 strings and token objects. As when they were decoded one after another,
+// the last timestamp or token in the document decides the version. 0 is returned if there are none.
+func schemaVersionOf(data []byte, t reflect.Type) int {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return 0
+	}
+	switch t {
+	case dateTimeType:
+		if data[0] == '"' {
+			return 2
+		} else if data[0] != 'n' {
+			return 1
+		}
+		return 0
+	case tokenType:
+		if data[0] == '{' {
+			return 2
+		} else if data[0] == '"' {
+			return 1
+		}
+		return 0
+	}
+	version := 0
+	switch {
+	case data[0] == '[' && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Struct):
+		elementType := t
+		if t.Kind() != reflect.Struct {
+			elementType = t.Elem()
+		}
+		var elements []json.RawMessage
+		if err := json.Unmarshal(data, &elements); err != nil {
+			return 0
+		}
+		for _, element := range elements {
+			if elementVersion := schemaVersionOf(element, elementType); elementVersion != 0 {
+				version = elementVersion
+			}
+		}
+	case data[0] == '{' && t.Kind() == reflect.Struct:
+		known := jsonFieldTypes(t)
+		// The fields are read in document order, since a map would lose which one came last
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		if _, err := decoder.Token(); err != nil {
+			return 0
+		}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return version
+			}
+			var value json.RawMessage
+			if err := decoder.Decode(&value); err != nil {
+				return version
+			}
+			name, _ := key.(string)
+			if fieldType, ok := known[strings.ToLower(name)]; ok {
+				if fieldVersion := schemaVersionOf(value, fieldType); fieldVersion != 0 {
+					version = fieldVersion
+				}
+			}
+		}
+	}
+	return version
+}
+
+// unknownJSONFields returns the fields of the JSON object in data whose keys are not in known.
+// It returns nil if every field is known.
+func unknownJSONFields(data []byte, known map[string]bool) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for key := range fields {
+		if known[strings.ToLower(key)] {
+			delete(fields, key)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+// appendJSONFields adds the extra fields, sorted by key, to the end of the marshaled JSON object in data
+func appendJSONFields(data []byte, extra map[string]json.RawMessage) ([]byte, error) {
+	if len(extra) == 0 {
+		return data, nil
+	}
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	output := append([]byte(nil), data[:len(data)-1]...)
+	for _, key := range keys {
+		if len(output) > 1 {
+			output = append(output, ',')
+		}
+		encodedKey, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, encodedKey...)
+		output = append(output, ':')
+		output = append(output, extra[key]...)
+	}
+	return append(output, '}'), nil
+}
+
+// zeroPointerPaths appends the path of field and slice indexes to every non-nil pointer to a zero value within v
+func zeroPointerPaths(v reflect.Value, path []int, paths *[][]int) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		if v.Elem().IsZero() {
+			*paths = append(*paths, append([]int(nil), path...))
+			return
+		}
+		zeroPointerPaths(v.Elem(), path, paths)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				zeroPointerPaths(v.Field(i), append(path, i), paths)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			zeroPointerPaths(v.Index(i), append(path, i), paths)
+		}
+	}
+}
+
+// restoreZeroPointer allocates the pointer found by following path from v, as recorded by zeroPointerPaths
+func restoreZeroPointer(v reflect.Value, path []int) {
+	for _, i := range path {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			v = v.Field(i)
+		case reflect.Slice:
+			if i >= v.Len() {
+				return
+			}
+			v = v.Index(i)
+		default:
+			return
+		}
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+}
+
+// deepCopy copies src into dst, allocating new pointers, slices and maps for all exported fields along the way.
+// Unexported struct fields are copied shallowly.
+func deepCopy(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.New(src.Type().Elem()))
+		deepCopy(dst.Elem(), src.Elem())
+	case reflect.Struct:
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if src.Type().Field(i).PkgPath == "" {
+				deepCopy(dst.Field(i), src.Field(i))
+			}
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(dst.Index(i), src.Index(i))
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		for _, key := range src.MapKeys() {
+			value := reflect.New(src.Type().Elem()).Elem()
+			deepCopy(value, src.MapIndex(key))
+			dst.SetMapIndex(key, value)
+		}
+	default:
+		dst.Set(src)
+	}
+}
+
+// dateTimeType is the reflect.Type of DateTime
+var dateTimeType = reflect.TypeOf(DateTime{})
+
+// truncateDateTimes walks v, truncating every DateTime to whole seconds in UTC and forgetting its original JSON
+// so that timestamps compare equal no matter which schema version they were read from
+func truncateDateTimes(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			truncateDateTimes(v.Elem())
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			truncateDateTimes(v.Index(i))
+		}
+	case reflect.Struct:
+		if v.Type() == dateTimeType {
+			dateTime := v.Addr().Interface().(*DateTime)
+			*dateTime = DateTime{Time: dateTime.Truncate(time.Second).UTC()}
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				truncateDateTimes(v.Field(i))
+			}
+		}
+	}
+}
+
+// copyJSONFields returns a deep copy of a set of captured JSON fields
+func copyJSONFields(fields map[string]json.RawMessage) map[string]json.RawMessage {
+	if fields == nil {
+		return nil
+	}
+	copied := make(map[string]json.RawMessage, len(fields))
+	for key, value := range fields {
+		copied[key] = append(json.RawMessage(nil), value...)
+	}
+	return copied
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// unquoteNumbers rewrites JSON strings that hold numbers into JSON numbers wherever the type t expects a number.
+// Types with their own JSON unmarshaling are left untouched. Numbers are written back in JSON number syntax,
+// so forms like +0.9, .5 and 0x1p-2 are accepted, and an error is returned for NaN and infinities, which JSON can't represent.
+func unquoteNumbers(data []byte, t reflect.Type) ([]byte, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		return data, nil
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64:
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return data, nil
+		}
+		value = strings.TrimSpace(value)
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return data, nil
+		}
+		if math.IsNaN(number) || math.IsInf(number, 0) {
+			return nil, fmt.Errorf("Unable to decode %q as a number", value)
+		}
+		return []byte(strconv.FormatFloat(number, 'g', -1, 64)), nil
+	case reflect.Slice:
+		var elements []json.RawMessage
+		if err := json.Unmarshal(data, &elements); err != nil {
+			return data, nil
+		}
+		for i, element := range elements {
+			unquoted, err := unquoteNumbers(element, t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = unquoted
+		}
+		return json.Marshal(elements)
+	case reflect.Struct:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return data, nil
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if field.PkgPath != "" || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			for key, value := range fields {
+				if !strings.EqualFold(key, name) {
+					continue
+				}
+				unquoted, err := unquoteNumbers(value, field.Type)
+				if err != nil {
+					return nil, err
+				}
+				fields[key] = unquoted
+			}
+		}
+		return json.Marshal(fields)
+	}
+	return data, nil
+}
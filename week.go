@@ -0,0 +1,55 @@
+package reporter
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoMetricData is returned when none of the snapshots being aggregated have the requested metric
+var ErrNoMetricData = errors.New("No data for metric")
+
+// Week is an aggregate of the days of a week
+type Week struct {
+	Start time.Time // The first day of the week
+	Days  []Day
+}
+
+// Average returns the mean of the named metric over every snapshot of the week that has it.
+// The metrics are the same as Day.TimeSeries. An error wrapping ErrNoMetricData is returned if no snapshot has the metric,
+// such as steps in a week of schema version 1 reports, which record missing steps as -1.
+func (w *Week) Average(metric string) (float64, error) {
+	value, err := timeSeriesMetric(metric)
+	if err != nil {
+		return 0, err
+	}
+	total := 0.0
+	count := 0
+	for i := range w.Days {
+		for j := range w.Days[i].Snapshots {
+			if v, ok := value(&w.Days[i].Snapshots[j]); ok {
+				total += v
+				count++
+			}
+		}
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("%w %s in the week starting %s", ErrNoMetricData, metric, w.Start.Format("2006-01-02"))
+	}
+	return total / float64(count), nil
+}
+
+// CompareWeeks returns how much the average of the named metric changed from week a to week b, as b's average minus a's.
+// Divide the delta by a.Average(metric) to get the relative change. An unknown metric returns an error listing the valid ones,
+// and an error wrapping ErrNoMetricData is returned if either week has no data for the metric.
+func CompareWeeks(a, b Week, metric string) (delta float64, err error) {
+	aAverage, err := a.Average(metric)
+	if err != nil {
+		return 0, err
+	}
+	bAverage, err := b.Average(metric)
+	if err != nil {
+		return 0, err
+	}
+	return bAverage - aAverage, nil
+}